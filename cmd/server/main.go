@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"fmt"
 	"net/http"
 	"os"
@@ -18,7 +20,9 @@ import (
 
 	apphttp "magnet-player/internal/http"
 	"magnet-player/internal/config"
+	"magnet-player/internal/domain"
 	"magnet-player/internal/downloader"
+	"magnet-player/internal/events"
 	"magnet-player/internal/repository/sqlite"
 	"magnet-player/internal/service"
 	"magnet-player/internal/storage"
@@ -52,6 +56,9 @@ func main() {
 	taskRepo := sqlite.NewTaskRepository(db)
 	fileRepo := sqlite.NewTaskFileRepository(db)
 	userRepo := sqlite.NewUserRepository(db)
+	sessionRepo := sqlite.NewSessionRepository(db)
+	uploadRepo := sqlite.NewUploadStateRepository(db)
+	settingsRepo := sqlite.NewSettingsRepository(db)
 
 	if err := taskRepo.Init(ctx); err != nil {
 		logger.Fatalf("init task repository: %v", err)
@@ -62,24 +69,70 @@ func main() {
 	if err := userRepo.Init(ctx); err != nil {
 		logger.Fatalf("init user repository: %v", err)
 	}
+	if err := sessionRepo.Init(ctx); err != nil {
+		logger.Fatalf("init session repository: %v", err)
+	}
+	if err := uploadRepo.Init(ctx); err != nil {
+		logger.Fatalf("init upload state repository: %v", err)
+	}
+	if err := settingsRepo.Init(ctx); err != nil {
+		logger.Fatalf("init settings repository: %v", err)
+	}
 
-	taskService := service.NewTaskService(taskRepo, fileRepo)
-	userService := service.NewUserService(userRepo, cfg.Auth.RegisterPassword)
+	taskService := service.NewTaskService(taskRepo, fileRepo, uploadRepo)
+	userService := service.NewUserService(userRepo, taskRepo, cfg.Auth.RegisterPassword, cfg.Auth.AdminUsername)
+	if err := userService.EnsureAdmin(ctx); err != nil {
+		logger.Fatalf("ensure admin user: %v", err)
+	}
+	sessionService := service.NewSessionService(sessionRepo, time.Duration(cfg.Auth.RefreshTTLHours)*time.Hour)
+	go pruneSessions(ctx, sessionService, logger)
+
+	defaultBandwidth, err := bandwidthFromConfig(cfg)
+	if err != nil {
+		logger.Fatalf("bandwidth config: %v", err)
+	}
+	seeding, err := seedingFromConfig(cfg)
+	if err != nil {
+		logger.Fatalf("seeding config: %v", err)
+	}
+	retry := retryFromConfig(cfg)
+	if err := retry.Validate(); err != nil {
+		logger.Fatalf("retry config: %v", err)
+	}
+	settingsService := service.NewSettingsService(settingsRepo, defaultBandwidth, cfg.Download.MaxActive)
+	bandwidth, err := settingsService.Bandwidth(ctx)
+	if err != nil {
+		logger.Warnf("load bandwidth settings: %v", err)
+		bandwidth = defaultBandwidth
+	}
+	maxActive, err := settingsService.MaxActive(ctx)
+	if err != nil {
+		logger.Warnf("load queue settings: %v", err)
+		maxActive = cfg.Download.MaxActive
+	}
 
 	storageSvc, err := buildStorage(ctx, cfg, logger)
 	if err != nil {
 		logger.Fatalf("setup storage: %v", err)
 	}
 
+	bus := events.NewBus(1024)
+
 	manager := downloader.NewManager(downloader.Config{
-		DownloadRoot:   cfg.Download.DataDir,
-		MaxConcurrent:  3,
-		StatusInterval: 2 * time.Second,
+		DownloadRoot:    cfg.Download.DataDir,
+		MaxConcurrent:   maxActive,
+		StatusInterval:  2 * time.Second,
+		MetadataTimeout: time.Duration(cfg.Download.MetadataTimeoutMinutes) * time.Minute,
+		StallTimeout:    time.Duration(cfg.Download.StallTimeoutMinutes) * time.Minute,
 		UploadOptions: storage.UploadOptions{
 			Bucket:    cfg.Storage.Bucket,
 			KeyPrefix: cfg.Storage.KeyPrefix,
 		},
-		Logger: logger,
+		Bandwidth: bandwidth,
+		Seeding:   seeding,
+		Retry:     retry,
+		Events:    bus,
+		Logger:    logger,
 	}, taskService, storageSvc)
 
 	if err := manager.Start(ctx); err != nil {
@@ -95,10 +148,14 @@ func main() {
 	handler := apphttp.NewHandler(
 		taskService,
 		manager,
+		bus,
 		storageSvc,
 		cfg.Storage.Bucket,
+		cfg.Storage.KeyPrefix,
 		cfg.Download.DataDir,
 		userService,
+		sessionService,
+		settingsService,
 		cfg.Auth.JWTSecret,
 		time.Duration(cfg.Auth.TokenTTLMinutes)*time.Minute,
 	)
@@ -130,7 +187,66 @@ func main() {
 	logger.Info("bye")
 }
 
+// sessionPruneInterval is how often expired and revoked sessions are deleted.
+const sessionPruneInterval = time.Hour
+
+// pruneSessions deletes dead sessions at startup and then periodically until
+// ctx is done.
+func pruneSessions(ctx context.Context, sessions service.SessionService, logger *logrus.Logger) {
+	ticker := time.NewTicker(sessionPruneInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := sessions.Prune(ctx); err != nil {
+			logger.Warnf("prune sessions: %v", err)
+		} else if n > 0 {
+			logger.Infof("pruned %d expired or revoked sessions", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// presignKey returns the key for filesystem download links. Without a
+// configured storage.presign_secret it is derived from the JWT secret, so a
+// link signature is never made with the key that signs sessions.
+func presignKey(cfg config.Config) []byte {
+	if secret := strings.TrimSpace(cfg.Storage.PresignSecret); secret != "" {
+		return []byte(secret)
+	}
+	mac := hmac.New(sha256.New, []byte(cfg.Auth.JWTSecret))
+	mac.Write([]byte("magnet-player storage presign"))
+	return mac.Sum(nil)
+}
+
 func buildStorage(ctx context.Context, cfg config.Config, logger *logrus.Logger) (storage.Service, error) {
+	switch driver := strings.ToLower(strings.TrimSpace(cfg.Storage.Driver)); driver {
+	case "", "s3":
+	case "filesystem", "fs", "local":
+		// presigned links are served by apphttp under /api/storage/files
+		store, err := storage.NewFilesystemService(cfg.Storage.Root, "/api/storage/files", presignKey(cfg))
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof("using filesystem storage at %s", cfg.Storage.Root)
+		return store, nil
+	case "webdav":
+		store, err := storage.NewWebDAVService(storage.WebDAVConfig{
+			URL:      cfg.Storage.WebDAV.URL,
+			Username: cfg.Storage.WebDAV.Username,
+			Password: cfg.Storage.WebDAV.Password,
+		})
+		if err != nil {
+			return nil, err
+		}
+		logger.Infof("using webdav storage at %s", cfg.Storage.WebDAV.URL)
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+
 	if cfg.Storage.Bucket == "" {
 		return nil, fmt.Errorf("storage bucket is required")
 	}
@@ -154,5 +270,60 @@ func buildStorage(ctx context.Context, cfg config.Config, logger *logrus.Logger)
 		}
 	})
 	logger.Infof("using s3 bucket %s (region %s)", cfg.Storage.Bucket, cfg.Storage.Region)
-	return storage.NewS3Service(client), nil
+	return storage.NewS3Service(client, storage.S3Options{
+		FileConcurrency: cfg.Storage.UploadConcurrency,
+		PartSize:        cfg.Storage.PartSizeMB << 20,
+		PartConcurrency: cfg.Storage.PartConcurrency,
+		RateLimit:       cfg.Storage.UploadRateLimit,
+	}), nil
+}
+
+func seedingFromConfig(cfg config.Config) (domain.SeedingPolicy, error) {
+	mode, err := domain.ParseSeedMode(cfg.Seeding.Mode)
+	if err != nil {
+		return domain.SeedingPolicy{}, err
+	}
+	policy := domain.SeedingPolicy{
+		Mode:     mode,
+		Ratio:    cfg.Seeding.Ratio,
+		Duration: time.Duration(cfg.Seeding.DurationMinutes) * time.Minute,
+	}
+	return policy, policy.Validate()
+}
+
+func retryFromConfig(cfg config.Config) domain.RetryPolicy {
+	return domain.RetryPolicy{
+		MaxAttempts:  cfg.Retry.MaxAttempts,
+		InitialDelay: time.Duration(cfg.Retry.InitialDelaySeconds) * time.Second,
+		MaxDelay:     time.Duration(cfg.Retry.MaxDelayMinutes) * time.Minute,
+		Multiplier:   cfg.Retry.Multiplier,
+	}
+}
+
+func bandwidthFromConfig(cfg config.Config) (domain.BandwidthSettings, error) {
+	settings := domain.BandwidthSettings{
+		Limits: domain.BandwidthLimits{
+			Download: cfg.Bandwidth.DownloadLimit,
+			Upload:   cfg.Bandwidth.UploadLimit,
+		},
+		Schedule: domain.BandwidthSchedule{
+			Enabled: cfg.Bandwidth.Schedule.Enabled,
+			Limits: domain.BandwidthLimits{
+				Download: cfg.Bandwidth.Schedule.DownloadLimit,
+				Upload:   cfg.Bandwidth.Schedule.UploadLimit,
+			},
+		},
+	}
+
+	var err error
+	if settings.Schedule.Start, err = domain.ParseClock(cfg.Bandwidth.Schedule.Start); err != nil {
+		return domain.BandwidthSettings{}, err
+	}
+	if settings.Schedule.End, err = domain.ParseClock(cfg.Bandwidth.Schedule.End); err != nil {
+		return domain.BandwidthSettings{}, err
+	}
+	if settings.Schedule.Days, err = domain.ParseWeekdays(strings.Split(cfg.Bandwidth.Schedule.Days, ",")); err != nil {
+		return domain.BandwidthSettings{}, err
+	}
+	return settings, settings.Validate()
 }
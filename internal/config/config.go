@@ -19,12 +19,69 @@ type Config struct {
 	}
 	Download struct {
 		DataDir string
+		// MaxActive is the default number of simultaneous downloads. A value
+		// saved through the API takes precedence.
+		MaxActive int `mapstructure:"max_active"`
+		// A download is marked stalled when it gets no metadata within
+		// MetadataTimeoutMinutes or makes no progress for StallTimeoutMinutes;
+		// 0 disables either check.
+		MetadataTimeoutMinutes int `mapstructure:"metadata_timeout_minutes"`
+		StallTimeoutMinutes    int `mapstructure:"stall_timeout_minutes"`
+	}
+	// Bandwidth holds the default torrent limits in bytes per second, 0 for
+	// unlimited. Limits saved through the API take precedence.
+	Bandwidth struct {
+		DownloadLimit int64 `mapstructure:"download_limit"`
+		UploadLimit   int64 `mapstructure:"upload_limit"`
+		// Schedule applies alternate limits between Start and End ("HH:MM",
+		// local time) on Days (comma separated names, empty for every day).
+		Schedule struct {
+			Enabled       bool
+			DownloadLimit int64 `mapstructure:"download_limit"`
+			UploadLimit   int64 `mapstructure:"upload_limit"`
+			Start         string
+			End           string
+			Days          string
+		}
+	}
+	// Seeding is the default policy after upload: Mode is "none", "ratio",
+	// "duration" or "forever".
+	Seeding struct {
+		Mode            string
+		Ratio           float64
+		DurationMinutes int `mapstructure:"duration_minutes"`
+	}
+	// Retry re-enqueues tasks that failed with a transient error, waiting
+	// InitialDelaySeconds after the first failure and Multiplier times longer
+	// after each further one, up to MaxDelayMinutes. MaxAttempts 0 disables it.
+	Retry struct {
+		MaxAttempts         int `mapstructure:"max_attempts"`
+		InitialDelaySeconds int `mapstructure:"initial_delay_seconds"`
+		MaxDelayMinutes     int `mapstructure:"max_delay_minutes"`
+		Multiplier          float64
 	}
 	Storage struct {
+		// Driver selects the storage backend: "s3", "filesystem" or "webdav".
+		Driver    string
 		Bucket    string
 		KeyPrefix string
 		Region    string
 		Endpoint  string
+		// Root is the library directory used by the filesystem driver.
+		Root string
+		// PresignSecret signs filesystem download links. When empty a key is
+		// derived from the JWT secret.
+		PresignSecret string `mapstructure:"presign_secret"`
+		// S3 upload tuning; UploadRateLimit is in bytes per second, 0 for unlimited.
+		UploadConcurrency int   `mapstructure:"upload_concurrency"`
+		PartSizeMB        int64 `mapstructure:"part_size_mb"`
+		PartConcurrency   int   `mapstructure:"part_concurrency"`
+		UploadRateLimit   int64 `mapstructure:"upload_rate_limit"`
+		WebDAV            struct {
+			URL      string
+			Username string
+			Password string
+		}
 	}
 	AWS struct {
 		Profile string
@@ -32,7 +89,9 @@ type Config struct {
 	Auth struct {
 		JWTSecret        string `mapstructure:"jwt_secret"`
 		TokenTTLMinutes  int    `mapstructure:"token_ttl_minutes"`
+		RefreshTTLHours  int    `mapstructure:"refresh_ttl_hours"`
 		RegisterPassword string `mapstructure:"register_password"`
+		AdminUsername    string `mapstructure:"admin_username"`
 	}
 }
 
@@ -48,14 +107,44 @@ func Load() (Config, error) {
 	v.SetDefault("server.addr", "0.0.0.0:8080")
 	v.SetDefault("database.path", "data/magnet.db")
 	v.SetDefault("download.datadir", "data/downloads")
+	v.SetDefault("download.max_active", 3)
+	v.SetDefault("download.metadata_timeout_minutes", 10)
+	v.SetDefault("download.stall_timeout_minutes", 30)
+	v.SetDefault("bandwidth.download_limit", 0)
+	v.SetDefault("bandwidth.upload_limit", 0)
+	v.SetDefault("bandwidth.schedule.enabled", false)
+	v.SetDefault("bandwidth.schedule.download_limit", 0)
+	v.SetDefault("bandwidth.schedule.upload_limit", 0)
+	v.SetDefault("bandwidth.schedule.start", "09:00")
+	v.SetDefault("bandwidth.schedule.end", "18:00")
+	v.SetDefault("bandwidth.schedule.days", "")
+	v.SetDefault("seeding.mode", "none")
+	v.SetDefault("seeding.ratio", 1.0)
+	v.SetDefault("seeding.duration_minutes", 24*60)
+	v.SetDefault("retry.max_attempts", 5)
+	v.SetDefault("retry.initial_delay_seconds", 30)
+	v.SetDefault("retry.max_delay_minutes", 30)
+	v.SetDefault("retry.multiplier", 2.0)
+	v.SetDefault("storage.driver", "s3")
 	v.SetDefault("storage.bucket", "")
 	v.SetDefault("storage.keyprefix", "magnet-tasks")
 	v.SetDefault("storage.region", "us-east-1")
 	v.SetDefault("storage.endpoint", "")
+	v.SetDefault("storage.root", "data/library")
+	v.SetDefault("storage.presign_secret", "")
+	v.SetDefault("storage.upload_concurrency", 2)
+	v.SetDefault("storage.part_size_mb", 16)
+	v.SetDefault("storage.part_concurrency", 4)
+	v.SetDefault("storage.upload_rate_limit", 0)
+	v.SetDefault("storage.webdav.url", "")
+	v.SetDefault("storage.webdav.username", "")
+	v.SetDefault("storage.webdav.password", "")
 	v.SetDefault("aws.profile", "")
 	v.SetDefault("auth.jwt_secret", "")
-	v.SetDefault("auth.token_ttl_minutes", 24*60)
+	v.SetDefault("auth.token_ttl_minutes", 15)
+	v.SetDefault("auth.refresh_ttl_hours", 30*24)
 	v.SetDefault("auth.register_password", "")
+	v.SetDefault("auth.admin_username", "")
 
 	v.SetConfigName("config")
 	v.AddConfigPath(".")
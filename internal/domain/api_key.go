@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix marks personal API keys so they can be told apart from JWTs.
+const APIKeyPrefix = "mk_"
+
+type APIKeyScope string
+
+const (
+	ScopeTasksRead   APIKeyScope = "tasks:read"
+	ScopeTasksWrite  APIKeyScope = "tasks:write"
+	ScopeStorageRead APIKeyScope = "storage:read"
+)
+
+// APIKey is a long-lived personal credential used by scripts. Only a hash of
+// the secret is stored; Prefix keeps enough of it to identify the key in lists.
+type APIKey struct {
+	ID         int64
+	UserID     int64
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []APIKeyScope
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// Allows reports whether the key grants scope. A key without scopes is unrestricted.
+func (k APIKey) Allows(scope APIKeyScope) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseAPIKeyScope validates a scope name.
+func ParseAPIKeyScope(name string) (APIKeyScope, error) {
+	scope := APIKeyScope(strings.ToLower(strings.TrimSpace(name)))
+	switch scope {
+	case ScopeTasksRead, ScopeTasksWrite, ScopeStorageRead:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("invalid scope %q", name)
+	}
+}
@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BandwidthLimits caps torrent traffic in bytes per second. Zero means unlimited.
+type BandwidthLimits struct {
+	Download int64
+	Upload   int64
+}
+
+// Validate rejects negative limits.
+func (l BandwidthLimits) Validate() error {
+	if l.Download < 0 || l.Upload < 0 {
+		return errors.New("bandwidth limits must not be negative")
+	}
+	return nil
+}
+
+// BandwidthSchedule switches to alternate limits during a daily time window,
+// for example to throttle downloads during work hours.
+type BandwidthSchedule struct {
+	Enabled bool
+	Limits  BandwidthLimits
+	// Start and End are minutes after local midnight. A window whose end is
+	// before its start runs past midnight.
+	Start int
+	End   int
+	// Days restricts the window to the weekdays it starts on; empty means every day.
+	Days []time.Weekday
+}
+
+// Active reports whether now falls inside the scheduled window.
+func (s BandwidthSchedule) Active(now time.Time) bool {
+	if !s.Enabled || s.Start == s.End {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	day := now.Weekday()
+	if s.Start < s.End {
+		return minute >= s.Start && minute < s.End && s.onDay(day)
+	}
+	if minute >= s.Start {
+		return s.onDay(day)
+	}
+	if minute < s.End {
+		// the window started the previous evening
+		return s.onDay((day + 6) % 7)
+	}
+	return false
+}
+
+func (s BandwidthSchedule) onDay(day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, d := range s.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the window and its limits.
+func (s BandwidthSchedule) Validate() error {
+	if err := s.Limits.Validate(); err != nil {
+		return err
+	}
+	if s.Start < 0 || s.Start >= 24*60 || s.End < 0 || s.End >= 24*60 {
+		return errors.New("schedule times must be within a day")
+	}
+	if s.Enabled && s.Start == s.End {
+		return errors.New("schedule start and end must differ")
+	}
+	return nil
+}
+
+// BandwidthSettings holds the global torrent limits and the optional scheduled profile.
+type BandwidthSettings struct {
+	Limits   BandwidthLimits
+	Schedule BandwidthSchedule
+}
+
+// Effective returns the limits that apply at the given time.
+func (s BandwidthSettings) Effective(now time.Time) BandwidthLimits {
+	if s.Schedule.Active(now) {
+		return s.Schedule.Limits
+	}
+	return s.Limits
+}
+
+func (s BandwidthSettings) Validate() error {
+	if err := s.Limits.Validate(); err != nil {
+		return err
+	}
+	return s.Schedule.Validate()
+}
+
+// ParseClock converts "HH:MM" into minutes after midnight.
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// FormatClock converts minutes after midnight into "HH:MM".
+func FormatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// ParseWeekday accepts full or three letter English day names.
+func ParseWeekday(name string) (time.Weekday, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if normalized == full || normalized == full[:3] {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", name)
+}
+
+// ParseWeekdays parses day names, ignoring blanks and duplicates.
+func ParseWeekdays(names []string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	seen := make(map[time.Weekday]struct{}, len(names))
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		day, err := ParseWeekday(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[day]; ok {
+			continue
+		}
+		seen[day] = struct{}{}
+		days = append(days, day)
+	}
+	return days, nil
+}
@@ -0,0 +1,346 @@
+package domain
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Magnet is a parsed BitTorrent magnet URI.
+type Magnet struct {
+	// InfoHash is the lower case hex v1 info-hash (xt=urn:btih).
+	InfoHash string
+	// InfoHashV2 is the lower case hex sha2-256 multihash of a v2 torrent
+	// (xt=urn:btmh), including its 1220 prefix.
+	InfoHashV2  string
+	DisplayName string
+	Trackers    []string
+	WebSeeds    []string
+	// Peers are x.pe peer addresses (host:port) to connect to directly.
+	Peers []string
+	// ExactLength is the total size announced by xl, zero when absent.
+	ExactLength int64
+	// SelectOnly holds the so= file selection as written, e.g. "0,2,4-6".
+	SelectOnly string
+}
+
+// SelectsFile reports whether the so= selection includes the file at index.
+// Every file is selected when the magnet has no selection.
+func (m *Magnet) SelectsFile(index int) bool {
+	if m.SelectOnly == "" {
+		return true
+	}
+	for _, part := range strings.Split(m.SelectOnly, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, _ := strconv.Atoi(from)
+		last := first
+		if isRange {
+			last, _ = strconv.Atoi(to)
+		}
+		if index >= first && index <= last {
+			return true
+		}
+	}
+	return false
+}
+
+// Key identifies the torrent for duplicate detection: the v1 info-hash when
+// present, the v2 one otherwise.
+func (m *Magnet) Key() string {
+	if m.InfoHash != "" {
+		return m.InfoHash
+	}
+	return m.InfoHashV2
+}
+
+// String returns the normalized URI: hashes in lower case hex, parameters
+// in a fixed order and duplicates removed.
+func (m *Magnet) String() string {
+	var params []string
+	if m.InfoHash != "" {
+		params = append(params, "xt=urn:btih:"+m.InfoHash)
+	}
+	if m.InfoHashV2 != "" {
+		params = append(params, "xt=urn:btmh:"+m.InfoHashV2)
+	}
+	if m.DisplayName != "" {
+		params = append(params, "dn="+url.QueryEscape(m.DisplayName))
+	}
+	if m.ExactLength > 0 {
+		params = append(params, "xl="+strconv.FormatInt(m.ExactLength, 10))
+	}
+	for _, tr := range m.Trackers {
+		params = append(params, "tr="+url.QueryEscape(tr))
+	}
+	for _, ws := range m.WebSeeds {
+		params = append(params, "ws="+url.QueryEscape(ws))
+	}
+	for _, peer := range m.Peers {
+		params = append(params, "x.pe="+url.QueryEscape(peer))
+	}
+	if m.SelectOnly != "" {
+		params = append(params, "so="+m.SelectOnly)
+	}
+	return "magnet:?" + strings.Join(params, "&")
+}
+
+// MagnetError lists the problems found in a magnet URI by parameter name.
+type MagnetError struct {
+	Fields map[string]string
+}
+
+func (e *MagnetError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + ": " + e.Fields[name]
+	}
+	return "invalid magnet URI: " + strings.Join(parts, "; ")
+}
+
+// ParseMagnet validates a magnet URI. It requires a btih or btmh exact
+// topic and checks the optional dn, tr, ws, xl and so parameters. x.pe peers
+// are kept as given and other parameters are dropped. Problems are reported
+// as a *MagnetError.
+func ParseMagnet(uri string) (*Magnet, error) {
+	fields := make(map[string]string)
+	fail := func(field, format string, args ...any) {
+		if _, ok := fields[field]; !ok {
+			fields[field] = fmt.Sprintf(format, args...)
+		}
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil || !strings.EqualFold(parsed.Scheme, "magnet") {
+		return nil, &MagnetError{Fields: map[string]string{"magnet": "must be a magnet: URI"}}
+	}
+	values, err := url.ParseQuery(parsed.RawQuery)
+	if err != nil {
+		return nil, &MagnetError{Fields: map[string]string{"magnet": "malformed query string"}}
+	}
+
+	m := &Magnet{}
+	for _, xt := range values["xt"] {
+		xt = strings.TrimSpace(xt)
+		lower := strings.ToLower(xt)
+		switch {
+		case strings.HasPrefix(lower, "urn:btih:"):
+			hash, ok := parseBTIH(xt[len("urn:btih:"):])
+			if !ok {
+				fail("xt", "btih must be 40 hex or 32 base32 characters")
+				continue
+			}
+			if m.InfoHash != "" && m.InfoHash != hash {
+				fail("xt", "conflicting btih info-hashes")
+				continue
+			}
+			m.InfoHash = hash
+		case strings.HasPrefix(lower, "urn:btmh:"):
+			hash, ok := parseBTMH(xt[len("urn:btmh:"):])
+			if !ok {
+				fail("xt", "btmh must be a hex sha2-256 multihash (1220 followed by 64 hex characters)")
+				continue
+			}
+			if m.InfoHashV2 != "" && m.InfoHashV2 != hash {
+				fail("xt", "conflicting btmh info-hashes")
+				continue
+			}
+			m.InfoHashV2 = hash
+		}
+	}
+	if m.InfoHash == "" && m.InfoHashV2 == "" {
+		fail("xt", "a btih or btmh info-hash is required")
+	}
+
+	if dn := values.Get("dn"); dn != "" {
+		m.DisplayName = strings.TrimSpace(dn)
+	}
+
+	m.Trackers = uniqueURLs(values["tr"], func(u *url.URL) bool {
+		switch strings.ToLower(u.Scheme) {
+		case "http", "https", "udp", "ws", "wss":
+			return u.Host != ""
+		}
+		return false
+	}, func(raw string) { fail("tr", "invalid tracker URL %q", raw) })
+
+	m.WebSeeds = uniqueURLs(values["ws"], func(u *url.URL) bool {
+		scheme := strings.ToLower(u.Scheme)
+		return (scheme == "http" || scheme == "https") && u.Host != ""
+	}, func(raw string) { fail("ws", "invalid web seed URL %q", raw) })
+
+	for _, peer := range values["x.pe"] {
+		if peer = strings.TrimSpace(peer); peer != "" && !slices.Contains(m.Peers, peer) {
+			m.Peers = append(m.Peers, peer)
+		}
+	}
+
+	if raw := strings.TrimSpace(values.Get("xl")); raw != "" {
+		size, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || size <= 0 {
+			fail("xl", "must be a positive number of bytes")
+		} else {
+			m.ExactLength = size
+		}
+	}
+
+	if raw := strings.TrimSpace(values.Get("so")); raw != "" {
+		if err := validateSelectOnly(raw); err != nil {
+			fail("so", "%v", err)
+		} else {
+			m.SelectOnly = raw
+		}
+	}
+
+	if len(fields) > 0 {
+		return nil, &MagnetError{Fields: fields}
+	}
+	return m, nil
+}
+
+// MagnetInfoHash returns the lower case hex BTIH info-hash of a magnet URI,
+// accepting both hex and base32 encoded hashes. Unlike ParseMagnet it ignores
+// every other parameter.
+func MagnetInfoHash(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "magnet" {
+		return "", fmt.Errorf("invalid magnet URI scheme")
+	}
+	values, err := url.ParseQuery(parsed.RawQuery)
+	if err != nil {
+		return "", err
+	}
+
+	for _, xt := range values["xt"] {
+		if !strings.HasPrefix(strings.ToLower(xt), "urn:btih:") {
+			continue
+		}
+		if hash, ok := parseBTIH(xt[len("urn:btih:"):]); ok {
+			return hash, nil
+		}
+	}
+
+	return "", fmt.Errorf("btih magnet xt not present")
+}
+
+// MagnetTrackers returns the tracker URLs (tr parameters) of a magnet URI.
+func MagnetTrackers(uri string) []string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil
+	}
+	values, err := url.ParseQuery(parsed.RawQuery)
+	if err != nil {
+		return nil
+	}
+	var trackers []string
+	for _, tr := range values["tr"] {
+		if tr = strings.TrimSpace(tr); tr != "" {
+			trackers = append(trackers, tr)
+		}
+	}
+	return trackers
+}
+
+// MergeMagnetTrackers appends the trackers of other that uri lacks and
+// reports how many were added. The rest of uri is kept as is.
+func MergeMagnetTrackers(uri, other string) (string, int) {
+	known := make(map[string]struct{})
+	for _, tr := range MagnetTrackers(uri) {
+		known[tr] = struct{}{}
+	}
+	added := 0
+	for _, tr := range MagnetTrackers(other) {
+		if _, ok := known[tr]; ok {
+			continue
+		}
+		known[tr] = struct{}{}
+		uri += "&tr=" + url.QueryEscape(tr)
+		added++
+	}
+	return uri, added
+}
+
+// parseBTIH decodes a hex or base32 v1 info-hash into lower case hex.
+func parseBTIH(value string) (string, bool) {
+	hash := strings.TrimSpace(value)
+	if len(hash) == 40 {
+		if _, err := hex.DecodeString(hash); err == nil {
+			return strings.ToLower(hash), true
+		}
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	decoded, err := encoding.DecodeString(strings.TrimRight(strings.ToUpper(hash), "="))
+	if err != nil || len(decoded) != 20 {
+		return "", false
+	}
+	return hex.EncodeToString(decoded), true
+}
+
+// parseBTMH checks a v2 multihash: sha2-256 (0x12), 32 bytes (0x20), then the digest.
+func parseBTMH(value string) (string, bool) {
+	hash := strings.ToLower(strings.TrimSpace(value))
+	if len(hash) != 68 || !strings.HasPrefix(hash, "1220") {
+		return "", false
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", false
+	}
+	return hash, true
+}
+
+// uniqueURLs trims and de-duplicates raw URLs, reporting the ones valid rejects.
+func uniqueURLs(raws []string, valid func(*url.URL) bool, invalid func(string)) []string {
+	var result []string
+	seen := make(map[string]struct{}, len(raws))
+	for _, raw := range raws {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || !valid(u) {
+			invalid(raw)
+			continue
+		}
+		if _, ok := seen[raw]; ok {
+			continue
+		}
+		seen[raw] = struct{}{}
+		result = append(result, raw)
+	}
+	return result
+}
+
+// validateSelectOnly checks a comma separated list of file indices and
+// inclusive ranges such as "0,2,4-6".
+func validateSelectOnly(value string) error {
+	for _, part := range strings.Split(value, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(from)
+		if err != nil || first < 0 {
+			return fmt.Errorf("invalid file index %q", part)
+		}
+		if !isRange {
+			continue
+		}
+		last, err := strconv.Atoi(to)
+		if err != nil || last < first {
+			return fmt.Errorf("invalid file range %q", part)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const (
+	testHash   = "0123456789abcdef0123456789abcdef01234567"
+	testBase32 = "AERUKZ4JVPG66AJDIVTYTK6N54ASGRLH"
+	testBTMH   = "1220" + testHash + "89abcdef0123456789abcdef"
+)
+
+func TestParseMagnet(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+		want *Magnet
+		// errFields lists the parameters a *MagnetError must report.
+		errFields []string
+	}{
+		{
+			name: "hex btih",
+			uri:  "magnet:?xt=urn:btih:" + testHash,
+			want: &Magnet{InfoHash: testHash},
+		},
+		{
+			name: "upper case hex btih",
+			uri:  "magnet:?xt=urn:btih:0123456789ABCDEF0123456789ABCDEF01234567",
+			want: &Magnet{InfoHash: testHash},
+		},
+		{
+			name: "base32 btih",
+			uri:  "magnet:?xt=urn:btih:" + testBase32,
+			want: &Magnet{InfoHash: testHash},
+		},
+		{
+			name: "lower case base32 btih",
+			uri:  "magnet:?xt=urn:btih:aerukz4jvpg66ajdivtytk6n54asgrlh",
+			want: &Magnet{InfoHash: testHash},
+		},
+		{
+			name:      "short btih",
+			uri:       "magnet:?xt=urn:btih:0123456789abcdef",
+			errFields: []string{"xt"},
+		},
+		{
+			name: "btmh",
+			uri:  "magnet:?xt=urn:btmh:" + testBTMH,
+			want: &Magnet{InfoHashV2: testBTMH},
+		},
+		{
+			name: "hybrid btih and btmh",
+			uri:  "magnet:?xt=urn:btih:" + testHash + "&xt=urn:btmh:" + testBTMH,
+			want: &Magnet{InfoHash: testHash, InfoHashV2: testBTMH},
+		},
+		{
+			name:      "btmh without sha2-256 prefix",
+			uri:       "magnet:?xt=urn:btmh:1320" + testHash + "89abcdef0123456789abcdef",
+			errFields: []string{"xt"},
+		},
+		{
+			name: "same btih in hex and base32",
+			uri:  "magnet:?xt=urn:btih:" + testHash + "&xt=urn:btih:" + testBase32,
+			want: &Magnet{InfoHash: testHash},
+		},
+		{
+			name:      "conflicting btih",
+			uri:       "magnet:?xt=urn:btih:" + testHash + "&xt=urn:btih:fedcba9876543210fedcba9876543210fedcba98",
+			errFields: []string{"xt"},
+		},
+		{
+			name:      "missing xt",
+			uri:       "magnet:?dn=movie",
+			errFields: []string{"xt"},
+		},
+		{
+			name:      "not a magnet",
+			uri:       "https://example.com/?xt=urn:btih:" + testHash,
+			errFields: []string{"magnet"},
+		},
+		{
+			name: "trackers and web seeds",
+			uri: "magnet:?xt=urn:btih:" + testHash +
+				"&tr=udp%3A%2F%2Ftracker.example%3A1337&tr=https%3A%2F%2Ft.example%2Fannounce&tr=wss%3A%2F%2Fws.example" +
+				"&tr=udp%3A%2F%2Ftracker.example%3A1337&ws=https%3A%2F%2Fseed.example%2Ffile",
+			want: &Magnet{
+				InfoHash: testHash,
+				Trackers: []string{"udp://tracker.example:1337", "https://t.example/announce", "wss://ws.example"},
+				WebSeeds: []string{"https://seed.example/file"},
+			},
+		},
+		{
+			name:      "tracker with unsupported scheme",
+			uri:       "magnet:?xt=urn:btih:" + testHash + "&tr=ftp%3A%2F%2Ftracker.example",
+			errFields: []string{"tr"},
+		},
+		{
+			name:      "udp web seed",
+			uri:       "magnet:?xt=urn:btih:" + testHash + "&ws=udp%3A%2F%2Fseed.example",
+			errFields: []string{"ws"},
+		},
+		{
+			name: "display name, length, peers and selection",
+			uri:  "magnet:?xt=urn:btih:" + testHash + "&dn=Big+Movie&xl=1024&x.pe=10.0.0.1%3A6881&x.pe=10.0.0.1%3A6881&so=0,2,4-6",
+			want: &Magnet{
+				InfoHash:    testHash,
+				DisplayName: "Big Movie",
+				ExactLength: 1024,
+				Peers:       []string{"10.0.0.1:6881"},
+				SelectOnly:  "0,2,4-6",
+			},
+		},
+		{
+			name:      "negative xl",
+			uri:       "magnet:?xt=urn:btih:" + testHash + "&xl=-1",
+			errFields: []string{"xl"},
+		},
+		{
+			name:      "non numeric xl",
+			uri:       "magnet:?xt=urn:btih:" + testHash + "&xl=big",
+			errFields: []string{"xl"},
+		},
+		{
+			name:      "reversed so range",
+			uri:       "magnet:?xt=urn:btih:" + testHash + "&so=6-4",
+			errFields: []string{"so"},
+		},
+		{
+			name:      "so with empty entry",
+			uri:       "magnet:?xt=urn:btih:" + testHash + "&so=1,,2",
+			errFields: []string{"so"},
+		},
+		{
+			name:      "every problem is reported",
+			uri:       "magnet:?tr=nope&xl=0&so=x",
+			errFields: []string{"so", "tr", "xl", "xt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMagnet(tt.uri)
+			if tt.errFields != nil {
+				var magnetErr *MagnetError
+				if !errors.As(err, &magnetErr) {
+					t.Fatalf("ParseMagnet error = %v, want *MagnetError", err)
+				}
+				for _, field := range tt.errFields {
+					if _, ok := magnetErr.Fields[field]; !ok {
+						t.Errorf("error fields %v lack %q", magnetErr.Fields, field)
+					}
+				}
+				if len(magnetErr.Fields) != len(tt.errFields) {
+					t.Errorf("error fields = %v, want only %v", magnetErr.Fields, tt.errFields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMagnet: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMagnet = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMagnetString(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+		want string
+	}{
+		{
+			name: "base32 hash becomes hex",
+			uri:  "magnet:?xt=urn:btih:" + testBase32,
+			want: "magnet:?xt=urn:btih:" + testHash,
+		},
+		{
+			name: "fixed parameter order without duplicates",
+			uri: "magnet:?so=1-2&x.pe=h%3A1&ws=https%3A%2F%2Fs.example&tr=udp%3A%2F%2Ft.example%3A1&tr=udp%3A%2F%2Ft.example%3A1" +
+				"&xl=10&dn=a+b%26c&xt=urn:btmh:" + testBTMH + "&xt=urn:btih:" + testHash + "&unknown=1",
+			want: "magnet:?xt=urn:btih:" + testHash + "&xt=urn:btmh:" + testBTMH +
+				"&dn=a+b%26c&xl=10&tr=udp%3A%2F%2Ft.example%3A1&ws=https%3A%2F%2Fs.example&x.pe=h%3A1&so=1-2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseMagnet(tt.uri)
+			if err != nil {
+				t.Fatalf("ParseMagnet: %v", err)
+			}
+			got := m.String()
+			if got != tt.want {
+				t.Errorf("String = %q, want %q", got, tt.want)
+			}
+			// the normalized form parses back to the same magnet
+			again, err := ParseMagnet(got)
+			if err != nil {
+				t.Fatalf("ParseMagnet(String): %v", err)
+			}
+			if !reflect.DeepEqual(again, m) {
+				t.Errorf("round trip = %+v, want %+v", again, m)
+			}
+		})
+	}
+}
+
+func TestMagnetKey(t *testing.T) {
+	if got := (&Magnet{InfoHash: testHash, InfoHashV2: testBTMH}).Key(); got != testHash {
+		t.Errorf("hybrid Key = %q, want the btih", got)
+	}
+	if got := (&Magnet{InfoHashV2: testBTMH}).Key(); got != testBTMH {
+		t.Errorf("v2 Key = %q, want the btmh", got)
+	}
+}
+
+func TestMagnetSelectsFile(t *testing.T) {
+	tests := []struct {
+		so       string
+		selected []int
+		skipped  []int
+	}{
+		{"", []int{0, 1, 99}, nil},
+		{"0,2,4-6", []int{0, 2, 4, 5, 6}, []int{1, 3, 7}},
+		{"3-3", []int{3}, []int{2, 4}},
+	}
+	for _, tt := range tests {
+		m := &Magnet{SelectOnly: tt.so}
+		for _, index := range tt.selected {
+			if !m.SelectsFile(index) {
+				t.Errorf("so=%q: file %d not selected", tt.so, index)
+			}
+		}
+		for _, index := range tt.skipped {
+			if m.SelectsFile(index) {
+				t.Errorf("so=%q: file %d selected", tt.so, index)
+			}
+		}
+	}
+}
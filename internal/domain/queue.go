@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Queue priorities. Waiting downloads start by priority first and queue
+// position second.
+const (
+	QueuePriorityLow    = -1
+	QueuePriorityNormal = 0
+	QueuePriorityHigh   = 1
+)
+
+// ParseQueuePriority converts a priority name (low, normal, high) into its numeric value.
+func ParseQueuePriority(name string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "low":
+		return QueuePriorityLow, nil
+	case "normal":
+		return QueuePriorityNormal, nil
+	case "high":
+		return QueuePriorityHigh, nil
+	default:
+		return 0, fmt.Errorf("unknown queue priority %q", name)
+	}
+}
+
+// QueuePriorityName is the inverse of ParseQueuePriority.
+func QueuePriorityName(priority int) string {
+	switch {
+	case priority < QueuePriorityNormal:
+		return "low"
+	case priority > QueuePriorityNormal:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// QueueMove names a reordering of a task within its priority.
+type QueueMove string
+
+const (
+	QueueMoveTop    QueueMove = "top"
+	QueueMoveUp     QueueMove = "up"
+	QueueMoveDown   QueueMove = "down"
+	QueueMoveBottom QueueMove = "bottom"
+)
+
+// ParseQueueMove validates a queue move name.
+func ParseQueueMove(name string) (QueueMove, error) {
+	move := QueueMove(strings.ToLower(strings.TrimSpace(name)))
+	switch move {
+	case QueueMoveTop, QueueMoveUp, QueueMoveDown, QueueMoveBottom:
+		return move, nil
+	default:
+		return "", fmt.Errorf("unknown queue move %q", name)
+	}
+}
+
+// QueuedStatuses are the statuses of tasks that have not finished downloading
+// and therefore take part in the download queue.
+var QueuedStatuses = []TaskStatus{
+	TaskStatusPending,
+	TaskStatusDownloading,
+	TaskStatusPaused,
+	TaskStatusStalled,
+}
+
+// Queued reports whether the task takes part in the download queue.
+func (t Task) Queued() bool {
+	for _, status := range QueuedStatuses {
+		if t.Status == status {
+			return true
+		}
+	}
+	return false
+}
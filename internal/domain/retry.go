@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// RetryPolicy decides whether and when a failed task is tried again.
+type RetryPolicy struct {
+	// MaxAttempts is the number of failures after which a task stays failed;
+	// zero disables automatic retries.
+	MaxAttempts int
+	// InitialDelay is the wait after the first failure. Each further failure
+	// multiplies it by Multiplier, up to MaxDelay.
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+}
+
+// Delay returns how long to wait before retrying after the given number of
+// failures, counting from one.
+func (p RetryPolicy) Delay(attempts int) time.Duration {
+	delay := float64(p.InitialDelay)
+	for i := 1; i < attempts; i++ {
+		delay *= p.Multiplier
+		if p.MaxDelay > 0 && delay >= float64(p.MaxDelay) {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// ShouldRetry reports whether another attempt is allowed after attempts failures.
+func (p RetryPolicy) ShouldRetry(attempts int) bool {
+	return attempts < p.MaxAttempts
+}
+
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 {
+		return errors.New("retry attempts must not be negative")
+	}
+	if p.MaxAttempts == 0 {
+		return nil
+	}
+	if p.InitialDelay <= 0 {
+		return errors.New("retry delay must be positive")
+	}
+	if p.MaxDelay > 0 && p.MaxDelay < p.InitialDelay {
+		return errors.New("maximum retry delay must not be below the initial delay")
+	}
+	if p.Multiplier < 1 {
+		return errors.New("retry multiplier must be at least 1")
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts:  5,
+		InitialDelay: 30 * time.Second,
+		MaxDelay:     5 * time.Minute,
+		Multiplier:   2,
+	}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{50, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := policy.Delay(tt.attempts); got != tt.want {
+			t.Errorf("Delay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+
+	uncapped := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Second, Multiplier: 3}
+	if got := uncapped.Delay(3); got != 9*time.Second {
+		t.Errorf("uncapped Delay(3) = %s, want 9s", got)
+	}
+	constant := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Minute, Multiplier: 1}
+	if got := constant.Delay(3); got != time.Minute {
+		t.Errorf("constant Delay(3) = %s, want 1m", got)
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialDelay: time.Second, Multiplier: 2}
+	for attempts, want := range map[int]bool{1: true, 2: true, 3: false, 4: false} {
+		if got := policy.ShouldRetry(attempts); got != want {
+			t.Errorf("ShouldRetry(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+	if (RetryPolicy{}).ShouldRetry(1) {
+		t.Error("a zero policy retried")
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		wantErr bool
+	}{
+		{"disabled", RetryPolicy{}, false},
+		{"valid", RetryPolicy{MaxAttempts: 3, InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 2}, false},
+		{"negative attempts", RetryPolicy{MaxAttempts: -1}, true},
+		{"no delay", RetryPolicy{MaxAttempts: 3, Multiplier: 2}, true},
+		{"cap below initial delay", RetryPolicy{MaxAttempts: 3, InitialDelay: time.Minute, MaxDelay: time.Second, Multiplier: 2}, true},
+		{"shrinking multiplier", RetryPolicy{MaxAttempts: 3, InitialDelay: time.Second, Multiplier: 0.5}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
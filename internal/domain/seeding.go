@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type SeedMode string
+
+const (
+	// SeedModeNone drops the torrent as soon as the upload finishes.
+	SeedModeNone     SeedMode = "none"
+	SeedModeRatio    SeedMode = "ratio"
+	SeedModeDuration SeedMode = "duration"
+	SeedModeForever  SeedMode = "forever"
+)
+
+// SeedingPolicy decides how long a task keeps seeding after its upload.
+type SeedingPolicy struct {
+	Mode SeedMode
+	// Ratio is the upload to size ratio to reach in SeedModeRatio.
+	Ratio float64
+	// Duration is how long to seed in SeedModeDuration.
+	Duration time.Duration
+}
+
+// Enabled reports whether the policy seeds at all.
+func (p SeedingPolicy) Enabled() bool {
+	return p.Mode != "" && p.Mode != SeedModeNone
+}
+
+// Satisfied reports whether seeding may stop given the ratio reached and the
+// time spent seeding so far.
+func (p SeedingPolicy) Satisfied(ratio float64, seeded time.Duration) bool {
+	switch p.Mode {
+	case SeedModeRatio:
+		return ratio >= p.Ratio
+	case SeedModeDuration:
+		return seeded >= p.Duration
+	case SeedModeForever:
+		return false
+	default:
+		return true
+	}
+}
+
+func (p SeedingPolicy) Validate() error {
+	switch p.Mode {
+	case SeedModeNone, SeedModeForever:
+		return nil
+	case SeedModeRatio:
+		if p.Ratio <= 0 {
+			return errors.New("seeding ratio must be positive")
+		}
+		return nil
+	case SeedModeDuration:
+		if p.Duration <= 0 {
+			return errors.New("seeding duration must be positive")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown seeding mode %q", p.Mode)
+	}
+}
+
+// ParseSeedMode validates a seeding mode name.
+func ParseSeedMode(name string) (SeedMode, error) {
+	mode := SeedMode(strings.ToLower(strings.TrimSpace(name)))
+	switch mode {
+	case SeedModeNone, SeedModeRatio, SeedModeDuration, SeedModeForever:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown seeding mode %q", name)
+	}
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Session tracks a login and the refresh token that can renew its access tokens.
+type Session struct {
+	ID               string
+	UserID           int64
+	RefreshTokenHash string
+	ExpiresAt        time.Time
+	RevokedAt        *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// Active reports whether the session can still be used at the given time.
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -1,6 +1,10 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type TaskStatus string
 
@@ -10,14 +14,44 @@ const (
 	TaskStatusPaused      TaskStatus = "paused"
 	TaskStatusDownloaded  TaskStatus = "downloaded"
 	TaskStatusUploading   TaskStatus = "uploading"
+	TaskStatusSeeding     TaskStatus = "seeding"
 	TaskStatusCompleted   TaskStatus = "completed"
 	TaskStatusFailed      TaskStatus = "failed"
+	// TaskStatusStalled is a download that got no metadata or made no progress
+	// in time. It holds no download slot and waits to be resumed.
+	TaskStatusStalled TaskStatus = "stalled"
 )
 
+// TaskStatuses lists every task status.
+var TaskStatuses = []TaskStatus{
+	TaskStatusPending,
+	TaskStatusDownloading,
+	TaskStatusPaused,
+	TaskStatusDownloaded,
+	TaskStatusUploading,
+	TaskStatusSeeding,
+	TaskStatusCompleted,
+	TaskStatusFailed,
+	TaskStatusStalled,
+}
+
+// ParseTaskStatus validates a task status name.
+func ParseTaskStatus(name string) (TaskStatus, error) {
+	status := TaskStatus(strings.ToLower(strings.TrimSpace(name)))
+	for _, known := range TaskStatuses {
+		if status == known {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("unknown task status %q", name)
+}
+
 // Task represents a magnet download task tracked by the system.
 type Task struct {
 	ID               int64
+	UserID           int64
 	MagnetURI        string
+	InfoHash         string
 	Status           TaskStatus
 	Progress         int
 	Speed            int64
@@ -36,7 +70,40 @@ type Task struct {
 	UpdatedAt        time.Time
 	DownloadedAt     *time.Time
 	UploadedAt       *time.Time
-	Files            []TaskFile
+	// DownloadLimit and UploadLimit cap this task in bytes per second; zero means
+	// only the global limits apply.
+	DownloadLimit int64
+	UploadLimit   int64
+	// SeedPolicy overrides the default seeding policy when set.
+	SeedPolicy *SeedingPolicy
+	// PeerUploaded counts the bytes sent to peers over the task's lifetime.
+	PeerUploaded     int64
+	SeedingStartedAt *time.Time
+	// Upload progress to storage, kept after the upload for reference.
+	UploadDoneBytes  int64
+	UploadTotalBytes int64
+	UploadSpeed      int64
+	// Attempts counts the failures since the task was created or manually
+	// retried. NextRetryAt is set while an automatic retry is pending.
+	Attempts    int
+	NextRetryAt *time.Time
+	// QueuePriority and QueuePosition order the task among waiting downloads;
+	// a higher priority goes first, then a lower position.
+	QueuePriority int
+	QueuePosition int64
+	// DisplayName and ExpectedSize come from the magnet's dn and xl parameters
+	// and describe the torrent until its metadata arrives.
+	DisplayName  string
+	ExpectedSize int64
+	Files        []TaskFile
+}
+
+// Ratio returns the bytes sent to peers relative to the torrent size.
+func (t Task) Ratio() float64 {
+	if t.TotalSize <= 0 {
+		return 0
+	}
+	return float64(t.PeerUploaded) / float64(t.TotalSize)
 }
 
 // TaskFile captures an individual file discovered within a torrent.
@@ -47,4 +114,53 @@ type TaskFile struct {
 	Size     int64
 	Path     string
 	Priority int
+	// UploadStatus is empty until the file is part of an upload.
+	UploadStatus FileUploadStatus
+}
+
+type FileUploadStatus string
+
+const (
+	FileUploadPending   FileUploadStatus = "pending"
+	FileUploadUploading FileUploadStatus = "uploading"
+	FileUploadUploaded  FileUploadStatus = "uploaded"
+)
+
+// File priorities understood by the downloader. They line up with the
+// torrent client's piece priorities so they can be applied directly.
+const (
+	FilePrioritySkip   = 0
+	FilePriorityNormal = 1
+	FilePriorityHigh   = 2
+)
+
+// Selected reports whether the file should be downloaded and uploaded.
+func (f TaskFile) Selected() bool {
+	return f.Priority != FilePrioritySkip
+}
+
+// ParseFilePriority converts a priority name (skip, normal, high) into its numeric value.
+func ParseFilePriority(name string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "skip":
+		return FilePrioritySkip, nil
+	case "normal":
+		return FilePriorityNormal, nil
+	case "high":
+		return FilePriorityHigh, nil
+	default:
+		return 0, fmt.Errorf("unknown file priority %q", name)
+	}
+}
+
+// FilePriorityName is the inverse of ParseFilePriority.
+func FilePriorityName(priority int) string {
+	switch {
+	case priority <= FilePrioritySkip:
+		return "skip"
+	case priority >= FilePriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
 }
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TaskSort names the order of a task list.
+type TaskSort string
+
+const (
+	TaskSortCreated  TaskSort = "created"
+	TaskSortUpdated  TaskSort = "updated"
+	TaskSortSize     TaskSort = "size"
+	TaskSortProgress TaskSort = "progress"
+)
+
+// ParseTaskSort validates a task sort name. An empty name sorts by creation.
+func ParseTaskSort(name string) (TaskSort, error) {
+	sort := TaskSort(strings.ToLower(strings.TrimSpace(name)))
+	switch sort {
+	case "":
+		return TaskSortCreated, nil
+	case TaskSortCreated, TaskSortUpdated, TaskSortSize, TaskSortProgress:
+		return sort, nil
+	default:
+		return "", fmt.Errorf("unknown task sort %q", name)
+	}
+}
+
+// TaskListOptions filters, orders and pages a task list.
+type TaskListOptions struct {
+	// UserID limits the list to one owner; zero lists the tasks of every user.
+	UserID   int64
+	Statuses []TaskStatus
+	// Name matches part of the torrent or display name, ignoring case.
+	Name string
+	// CreatedFrom is inclusive and CreatedTo exclusive.
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Sort        TaskSort
+	Ascending   bool
+	// Cursor continues after the last task of a previous page. A zero Limit
+	// returns every remaining task.
+	Cursor string
+	Limit  int
+	// OmitFiles skips loading the files of each task.
+	OmitFiles bool
+}
+
+// TaskPage is one page of a task list.
+type TaskPage struct {
+	Tasks []Task
+	// Total counts every task matching the filters, across all pages.
+	Total int
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
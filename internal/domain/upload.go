@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// UploadObject records how far the upload of one file of a task has got, so
+// an interrupted upload can pick up where it stopped.
+type UploadObject struct {
+	TaskID int64
+	Key    string
+	Size   int64
+	// UploadID identifies an in-flight multipart upload; empty for single requests.
+	UploadID  string
+	PartSize  int64
+	Completed bool
+	// ETag is the remote entity tag of the finished object.
+	ETag      string
+	Parts     []UploadPart
+	UpdatedAt time.Time
+}
+
+// UploadPart is a finished part of a multipart upload.
+type UploadPart struct {
+	Number int32
+	ETag   string
+	Size   int64
+}
@@ -2,11 +2,25 @@ package domain
 
 import "time"
 
+type UserRole string
+
+const (
+	UserRoleAdmin UserRole = "admin"
+	UserRoleUser  UserRole = "user"
+)
+
 // User represents an authenticated user of the system.
 type User struct {
 	ID           int64
 	Username     string
 	PasswordHash string
+	Role         UserRole
+	Disabled     bool
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 }
+
+// IsAdmin reports whether the user holds the admin role.
+func (u User) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
@@ -0,0 +1,195 @@
+package downloader
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"golang.org/x/time/rate"
+
+	"magnet-player/internal/domain"
+)
+
+const (
+	// minLimiterBurst matches the torrent client's default upload burst; the
+	// client panics when the upload burst is smaller than a requested chunk.
+	minLimiterBurst = 1 << 20
+	// bandwidthScheduleInterval is how often the scheduled profile is re-evaluated.
+	bandwidthScheduleInterval = 30 * time.Second
+	// throttleInterval is how often per-task caps are re-evaluated.
+	throttleInterval = 250 * time.Millisecond
+)
+
+// Bandwidth returns the global limits and schedule currently in use.
+func (m *manager) Bandwidth() domain.BandwidthSettings {
+	m.bandwidthMu.Lock()
+	defer m.bandwidthMu.Unlock()
+	return m.bandwidth
+}
+
+// SetBandwidth replaces the global limits and applies them immediately.
+func (m *manager) SetBandwidth(settings domain.BandwidthSettings) {
+	m.bandwidthMu.Lock()
+	m.bandwidth = settings
+	m.bandwidthMu.Unlock()
+	m.applyBandwidth(time.Now())
+}
+
+// ApplyTaskBandwidth pushes the stored per-task caps to the running task, if any.
+func (m *manager) ApplyTaskBandwidth(ctx context.Context, taskID int64) error {
+	handle, ok := m.getTaskHandle(taskID)
+	if !ok {
+		return nil
+	}
+	task, err := m.taskService.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	handle.throttle.set(domain.BandwidthLimits{Download: task.DownloadLimit, Upload: task.UploadLimit})
+	return nil
+}
+
+// applyBandwidth sets the client limiters to the limits in effect at now.
+func (m *manager) applyBandwidth(now time.Time) {
+	m.bandwidthMu.Lock()
+	defer m.bandwidthMu.Unlock()
+
+	scheduled := m.bandwidth.Schedule.Active(now)
+	if scheduled != m.scheduled {
+		if scheduled {
+			m.cfg.Logger.Info("switching to scheduled bandwidth limits")
+		} else {
+			m.cfg.Logger.Info("switching to regular bandwidth limits")
+		}
+		m.scheduled = scheduled
+	}
+
+	limits := m.bandwidth.Effective(now)
+	setLimiterRate(m.downloadLimiter, limits.Download)
+	setLimiterRate(m.uploadLimiter, limits.Upload)
+}
+
+func (m *manager) runBandwidthSchedule() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(bandwidthScheduleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.applyBandwidth(now)
+		}
+	}
+}
+
+func newLimiter() *rate.Limiter {
+	return rate.NewLimiter(rate.Inf, minLimiterBurst)
+}
+
+// setLimiterRate applies a bytes per second limit, zero meaning unlimited. The
+// burst allows one second of traffic so short stalls are made up for.
+func setLimiterRate(l *rate.Limiter, bytesPerSecond int64) {
+	if bytesPerSecond <= 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	l.SetBurst(int(max(bytesPerSecond, minLimiterBurst)))
+	l.SetLimit(rate.Limit(bytesPerSecond))
+}
+
+// taskThrottle enforces per-task caps. The torrent client only limits traffic
+// globally, so the throttle watches the torrent's data counters and suspends
+// transfers for the torrent whenever it gets ahead of its budget.
+type taskThrottle struct {
+	download atomic.Int64
+	upload   atomic.Int64
+}
+
+func newTaskThrottle(limits domain.BandwidthLimits) *taskThrottle {
+	th := &taskThrottle{}
+	th.set(limits)
+	return th
+}
+
+func (th *taskThrottle) set(limits domain.BandwidthLimits) {
+	th.download.Store(limits.Download)
+	th.upload.Store(limits.Upload)
+}
+
+// run throttles t until ctx is done, then lifts any suspension it imposed.
+func (th *taskThrottle) run(ctx context.Context, t *torrent.Torrent) {
+	var (
+		down, up           byteBudget
+		downOpen, upOpen   = true, true
+		lastRead, lastSent int64
+		lastTick           = time.Now()
+	)
+	stats := t.Stats()
+	lastRead, lastSent = stats.BytesReadData.Int64(), stats.BytesWrittenData.Int64()
+
+	defer func() {
+		if !downOpen {
+			t.AllowDataDownload()
+		}
+		if !upOpen {
+			t.AllowDataUpload()
+		}
+	}()
+
+	ticker := time.NewTicker(throttleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			downLimit, upLimit := th.download.Load(), th.upload.Load()
+			elapsed := now.Sub(lastTick)
+			lastTick = now
+
+			stats := t.Stats()
+			read, sent := stats.BytesReadData.Int64(), stats.BytesWrittenData.Int64()
+			allowDown := down.allow(downLimit, read-lastRead, elapsed)
+			allowUp := up.allow(upLimit, sent-lastSent, elapsed)
+			lastRead, lastSent = read, sent
+
+			if allowDown != downOpen {
+				if allowDown {
+					t.AllowDataDownload()
+				} else {
+					t.DisallowDataDownload()
+				}
+				downOpen = allowDown
+			}
+			if allowUp != upOpen {
+				if allowUp {
+					t.AllowDataUpload()
+				} else {
+					t.DisallowDataUpload()
+				}
+				upOpen = allowUp
+			}
+		}
+	}
+}
+
+// byteBudget is a token bucket charged with traffic after it was observed.
+type byteBudget struct {
+	tokens float64
+}
+
+// allow refills the budget for elapsed, charges used and reports whether the
+// transfer may continue. At most one second of unused budget is kept.
+func (b *byteBudget) allow(limit, used int64, elapsed time.Duration) bool {
+	if limit <= 0 {
+		b.tokens = 0
+		return true
+	}
+	b.tokens += elapsed.Seconds()*float64(limit) - float64(used)
+	if b.tokens > float64(limit) {
+		b.tokens = float64(limit)
+	}
+	return b.tokens > 0
+}
@@ -1,20 +1,25 @@
 package downloader
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 
 	"magnet-player/internal/domain"
+	"magnet-player/internal/events"
 	"magnet-player/internal/service"
 	"magnet-player/internal/storage"
 )
@@ -26,15 +31,59 @@ type Manager interface {
 	Enqueue(ctx context.Context, taskID int64) error
 	Resume(ctx context.Context) error
 	Cancel(ctx context.Context, taskID int64) error
+	Pause(ctx context.Context, taskID int64) error
+	ResumeTask(ctx context.Context, taskID int64) error
+	ApplyFilePriorities(ctx context.Context, taskID int64) error
+	OpenStream(ctx context.Context, taskID int64, filePath string) (*FileStream, error)
+	Bandwidth() domain.BandwidthSettings
+	SetBandwidth(settings domain.BandwidthSettings)
+	ApplyTaskBandwidth(ctx context.Context, taskID int64) error
+	ApplySeedingPolicy(ctx context.Context, taskID int64) error
+	Retry(ctx context.Context, taskID int64) error
+	MaxActive() int
+	SetMaxActive(n int)
+	ApplyQueueOrder(ctx context.Context) error
+	ApplyTrackers(ctx context.Context, taskID int64) error
+}
+
+var (
+	// ErrInvalidTaskState is returned when a task cannot transition to the requested state.
+	ErrInvalidTaskState = errors.New("invalid task state")
+	// ErrStreamUnavailable is returned when a task has no running torrent to stream from.
+	ErrStreamUnavailable = errors.New("stream unavailable")
+)
+
+// FileStream is a seekable view of a torrent file. Reads block until the
+// requested pieces arrive, and the read position drives piece priorities.
+type FileStream struct {
+	io.ReadSeekCloser
+	Name string
+	Size int64
 }
 
 type Config struct {
-	DownloadRoot   string
+	DownloadRoot string
+	// MaxConcurrent is the initial number of active downloads; see SetMaxActive.
 	MaxConcurrent  int
 	StatusInterval time.Duration
 	TrackerList    []string
-	UploadOptions  storage.UploadOptions
-	Logger         *logrus.Logger
+	// MetadataTimeout and StallTimeout bound how long a download may wait for
+	// torrent metadata or go without progress before it is marked stalled.
+	// Zero disables the check.
+	MetadataTimeout time.Duration
+	StallTimeout    time.Duration
+	// StreamReadahead is the number of bytes prioritized ahead of a stream's read position.
+	StreamReadahead int64
+	UploadOptions   storage.UploadOptions
+	// Bandwidth holds the initial global torrent limits; see SetBandwidth.
+	Bandwidth domain.BandwidthSettings
+	// Seeding is the policy for tasks without their own.
+	Seeding domain.SeedingPolicy
+	// Retry decides when failed tasks are re-enqueued automatically.
+	Retry domain.RetryPolicy
+	// Events receives task status and progress updates; nil disables publishing.
+	Events *events.Bus
+	Logger *logrus.Logger
 }
 
 type manager struct {
@@ -43,18 +92,34 @@ type manager struct {
 	taskService service.TaskService
 	storage     storage.Service
 
-	sem    chan struct{}
+	queue  *downloadQueue
 	wg     sync.WaitGroup
 	ctx    context.Context
 	cancel context.CancelFunc
 	mu     sync.Mutex
 	active map[int64]*taskHandle
+	// torrents maps the info-hash of each torrent in use to a channel closed
+	// when its task lets go. The client holds one torrent per info-hash, so
+	// tasks of different users for the same torrent take turns.
+	torrents map[string]chan struct{}
+
+	downloadLimiter *rate.Limiter
+	uploadLimiter   *rate.Limiter
+	bandwidthMu     sync.Mutex
+	bandwidth       domain.BandwidthSettings
+	scheduled       bool
 }
 
 type taskHandle struct {
-	cancel  context.CancelFunc
-	torrent *torrent.Torrent
-	done    chan struct{}
+	cancel   context.CancelFunc
+	torrent  *torrent.Torrent
+	throttle *taskThrottle
+	done     chan struct{}
+	// peerUploadedBase is the task's peer upload total before torrent was added.
+	peerUploadedBase int64
+	seedPolicy       atomic.Pointer[domain.SeedingPolicy]
+	// releaseSlot frees the concurrency slot early; nil when none is held.
+	releaseSlot func()
 }
 
 func NewManager(cfg Config, taskService service.TaskService, storage storage.Service) Manager {
@@ -70,12 +135,20 @@ func NewManager(cfg Config, taskService service.TaskService, storage storage.Ser
 	if len(cfg.TrackerList) == 0 {
 		cfg.TrackerList = defaultTrackers()
 	}
+	if cfg.StreamReadahead <= 0 {
+		cfg.StreamReadahead = 16 << 20
+	}
 	return &manager{
 		cfg:         cfg,
 		taskService: taskService,
 		storage:     storage,
-		sem:         make(chan struct{}, cfg.MaxConcurrent),
+		queue:       newDownloadQueue(cfg.MaxConcurrent),
 		active:      make(map[int64]*taskHandle),
+		torrents:    make(map[string]chan struct{}),
+
+		downloadLimiter: newLimiter(),
+		uploadLimiter:   newLimiter(),
+		bandwidth:       cfg.Bandwidth,
 	}
 }
 
@@ -87,7 +160,10 @@ func (m *manager) Start(ctx context.Context) error {
 	clientConfig := torrent.NewDefaultClientConfig()
 	clientConfig.DataDir = m.cfg.DownloadRoot
 	clientConfig.NoUpload = false
-	clientConfig.Seed = false
+	clientConfig.Seed = true
+	clientConfig.DownloadRateLimiter = m.downloadLimiter
+	clientConfig.UploadRateLimiter = m.uploadLimiter
+	m.applyBandwidth(time.Now())
 
 	client, err := torrent.NewClient(clientConfig)
 	if err != nil {
@@ -96,6 +172,11 @@ func (m *manager) Start(ctx context.Context) error {
 
 	m.client = client
 	m.ctx, m.cancel = context.WithCancel(ctx)
+
+	m.wg.Add(2)
+	go m.runBandwidthSchedule()
+	go m.runRetryScheduler()
+
 	m.cfg.Logger.Infof("download manager started, data dir: %s", m.cfg.DownloadRoot)
 	return nil
 }
@@ -120,12 +201,15 @@ func (m *manager) Enqueue(ctx context.Context, taskID int64) error {
 	return nil
 }
 
+// Resume re-spawns unfinished tasks after a restart. Paused tasks are left
+// alone until they are explicitly resumed.
 func (m *manager) Resume(ctx context.Context) error {
 	tasks, err := m.taskService.ListByStatuses(ctx,
 		domain.TaskStatusPending,
 		domain.TaskStatusDownloading,
 		domain.TaskStatusDownloaded,
 		domain.TaskStatusUploading,
+		domain.TaskStatusSeeding,
 	)
 	if err != nil {
 		return err
@@ -140,46 +224,88 @@ func (m *manager) Resume(ctx context.Context) error {
 func (m *manager) spawnTask(task domain.Task) {
 	taskCtx, cancel := context.WithCancel(m.ctx)
 	handle := &taskHandle{
-		cancel: cancel,
-		done:   make(chan struct{}),
+		cancel:   cancel,
+		throttle: newTaskThrottle(domain.BandwidthLimits{Download: task.DownloadLimit, Upload: task.UploadLimit}),
+		done:     make(chan struct{}),
 	}
+	policy := m.seedingPolicy(&task)
+	handle.seedPolicy.Store(&policy)
 	m.registerTask(task.ID, handle)
 
 	m.wg.Add(1)
 	go func() {
 		defer m.wg.Done()
 		defer func() {
-			m.unregisterTask(task.ID)
+			m.unregisterTask(task.ID, handle)
 			close(handle.done)
 		}()
-		select {
-		case <-m.ctx.Done():
+		releaseTorrent, err := m.claimTorrent(taskCtx, task.InfoHash)
+		if err != nil {
 			return
-		case <-taskCtx.Done():
-			return
-		case m.sem <- struct{}{}:
-			defer func() { <-m.sem }()
-			m.handleTask(taskCtx, handle, &task)
 		}
+		defer releaseTorrent()
+		// seeding tasks only share bandwidth, not download slots
+		if task.Status != domain.TaskStatusSeeding {
+			release, err := m.queue.acquire(taskCtx, task.ID, task.QueuePriority, task.QueuePosition)
+			if err != nil {
+				return
+			}
+			handle.releaseSlot = release
+			defer release()
+		}
+		m.handleTask(taskCtx, handle, &task)
 	}()
 }
 
+// claimTorrent waits until no other task uses the torrent and returns the
+// function that lets it go.
+func (m *manager) claimTorrent(ctx context.Context, infoHash string) (func(), error) {
+	if infoHash == "" {
+		return func() {}, nil
+	}
+	for {
+		m.mu.Lock()
+		busy, ok := m.torrents[infoHash]
+		if !ok {
+			done := make(chan struct{})
+			m.torrents[infoHash] = done
+			m.mu.Unlock()
+			return func() {
+				m.mu.Lock()
+				delete(m.torrents, infoHash)
+				m.mu.Unlock()
+				close(done)
+			}, nil
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-busy:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
 func (m *manager) registerTask(id int64, handle *taskHandle) {
 	m.mu.Lock()
 	m.active[id] = handle
 	m.mu.Unlock()
 }
 
-func (m *manager) unregisterTask(id int64) {
+func (m *manager) unregisterTask(id int64, handle *taskHandle) {
 	m.mu.Lock()
-	delete(m.active, id)
+	if current, ok := m.active[id]; ok && current == handle {
+		delete(m.active, id)
+	}
 	m.mu.Unlock()
 }
 
-func (m *manager) setTaskTorrent(id int64, t *torrent.Torrent) {
+func (m *manager) setTaskTorrent(id int64, t *torrent.Torrent, peerUploadedBase int64) {
 	m.mu.Lock()
 	if handle, ok := m.active[id]; ok {
 		handle.torrent = t
+		handle.peerUploadedBase = peerUploadedBase
 	}
 	m.mu.Unlock()
 }
@@ -214,6 +340,82 @@ func (m *manager) Cancel(ctx context.Context, taskID int64) error {
 	}
 }
 
+// Pause stops an active download and releases its concurrency slot. Partial
+// data and piece completion stay in the download root so a later ResumeTask
+// continues from where the torrent left off.
+func (m *manager) Pause(ctx context.Context, taskID int64) error {
+	task, err := m.taskService.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	switch task.Status {
+	case domain.TaskStatusPaused:
+		return nil
+	case domain.TaskStatusPending, domain.TaskStatusDownloading, domain.TaskStatusStalled:
+	default:
+		return fmt.Errorf("%w: cannot pause %s task", ErrInvalidTaskState, task.Status)
+	}
+
+	if handle, ok := m.getTaskHandle(taskID); ok {
+		handle.cancel()
+		select {
+		case <-handle.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		// the download may have finished while the handle was winding down
+		task, err = m.taskService.GetTask(ctx, taskID)
+		if err != nil {
+			return err
+		}
+		switch task.Status {
+		case domain.TaskStatusPaused:
+			return nil
+		case domain.TaskStatusPending, domain.TaskStatusDownloading, domain.TaskStatusStalled:
+		case domain.TaskStatusDownloaded, domain.TaskStatusUploading, domain.TaskStatusSeeding:
+			// cancelling interrupted the upload or seeding; let it carry on
+			m.spawnTask(*task)
+			return fmt.Errorf("%w: task reached %s before it could be paused", ErrInvalidTaskState, task.Status)
+		default:
+			return fmt.Errorf("%w: task reached %s before it could be paused", ErrInvalidTaskState, task.Status)
+		}
+	}
+
+	if err := m.taskService.UpdateStatus(ctx, taskID, domain.TaskStatusPaused, nil); err != nil {
+		return err
+	}
+	m.publishStatus(task, domain.TaskStatusPaused, "")
+	if err := m.taskService.UpdateProgress(ctx, taskID, task.Progress, 0, task.DownloadedBytes, 0, 0, 0, 0, 0); err != nil {
+		m.cfg.Logger.WithField("task_id", taskID).Warnf("reset progress stats: %v", err)
+	}
+	m.cfg.Logger.WithField("task_id", taskID).Info("task paused")
+	return nil
+}
+
+// ResumeTask puts a paused or stalled task back into the download queue.
+func (m *manager) ResumeTask(ctx context.Context, taskID int64) error {
+	task, err := m.taskService.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if task.Status != domain.TaskStatusPaused && task.Status != domain.TaskStatusStalled {
+		return fmt.Errorf("%w: cannot resume %s task", ErrInvalidTaskState, task.Status)
+	}
+	if _, ok := m.getTaskHandle(taskID); ok {
+		return nil
+	}
+
+	if err := m.taskService.UpdateStatus(ctx, taskID, domain.TaskStatusPending, nil); err != nil {
+		return err
+	}
+	m.publishStatus(task, domain.TaskStatusPending, "")
+	task.Status = domain.TaskStatusPending
+	m.spawnTask(*task)
+	m.cfg.Logger.WithField("task_id", taskID).Info("task resumed")
+	return nil
+}
+
 func (m *manager) handleTask(ctx context.Context, handle *taskHandle, task *domain.Task) {
 	logger := m.cfg.Logger.WithField("task_id", task.ID)
 	switch task.Status {
@@ -222,11 +424,15 @@ func (m *manager) handleTask(ctx context.Context, handle *taskHandle, task *doma
 		return
 	case domain.TaskStatusDownloaded:
 		logger.Info("task already downloaded, resuming upload")
-		m.uploadAndCleanup(ctx, task)
+		m.uploadAndCleanup(ctx, handle, task)
 		return
 	case domain.TaskStatusUploading:
 		logger.Info("task mid-upload, resuming upload")
-		m.uploadAndCleanup(ctx, task)
+		m.uploadAndCleanup(ctx, handle, task)
+		return
+	case domain.TaskStatusSeeding:
+		logger.Info("resuming seeding")
+		m.seed(ctx, handle, task)
 		return
 	}
 
@@ -235,32 +441,67 @@ func (m *manager) handleTask(ctx context.Context, handle *taskHandle, task *doma
 		return
 	}
 	task.Status = domain.TaskStatusDownloading
+	m.publishStatus(task, task.Status, "")
 
-	t, err := m.client.AddMagnet(task.MagnetURI)
+	stored, err := m.taskService.GetMetainfo(ctx, task.ID)
 	if err != nil {
-		m.failTask(ctx, task.ID, fmt.Errorf("add magnet: %w", err))
+		logger.Warnf("load metainfo: %v", err)
+	}
+	t, err := m.addTorrent(task, stored)
+	if err != nil {
+		m.failTask(ctx, task, permanent(err))
 		return
 	}
 	defer t.Drop()
-	m.setTaskTorrent(task.ID, t)
-
-	for _, tracker := range m.cfg.TrackerList {
-		t.AddTrackers([][]string{{tracker}})
+	peerUploadedBase := task.PeerUploaded
+	m.setTaskTorrent(task.ID, t, peerUploadedBase)
+
+	throttleCtx, stopThrottle := context.WithCancel(ctx)
+	defer stopThrottle()
+	go handle.throttle.run(throttleCtx, t)
+
+	addTrackers(t, m.cfg.TrackerList)
+	// trackers merged from duplicate magnets are not in the stored metainfo
+	addTrackers(t, domain.MagnetTrackers(task.MagnetURI))
+	// neither are web seeds and x.pe peers; tasks created before magnets
+	// were validated may not parse, and keep only their trackers
+	magnet, err := domain.ParseMagnet(task.MagnetURI)
+	if err == nil {
+		addMagnetSources(t, magnet)
 	}
 
+	var metadataTimeout <-chan time.Time
+	if m.cfg.MetadataTimeout > 0 {
+		timer := time.NewTimer(m.cfg.MetadataTimeout)
+		defer timer.Stop()
+		metadataTimeout = timer.C
+	}
 	select {
 	case <-ctx.Done():
 		logger.Info("task cancelled before fetching metadata")
 		return
+	case <-metadataTimeout:
+		m.stallTask(ctx, task, fmt.Sprintf("no metadata received within %s", m.cfg.MetadataTimeout))
+		return
 	case <-t.GotInfo():
 	}
 
 	info := t.Info()
 	if info == nil {
-		m.failTask(ctx, task.ID, fmt.Errorf("missing torrent info"))
+		m.failTask(ctx, task, transient(fmt.Errorf("missing torrent info")))
 		return
 	}
 
+	if len(stored) == 0 {
+		var buf bytes.Buffer
+		mi := t.Metainfo()
+		if err := mi.Write(&buf); err != nil {
+			logger.Warnf("encode metainfo: %v", err)
+		} else if err := m.taskService.SaveMetainfo(ctx, task.ID, buf.Bytes()); err != nil {
+			logger.Warnf("save metainfo: %v", err)
+		}
+	}
+
 	totalLength := info.TotalLength()
 	name := info.BestName()
 	localPath := filepath.Join(m.cfg.DownloadRoot, name)
@@ -269,30 +510,52 @@ func (m *manager) handleTask(ctx context.Context, handle *taskHandle, task *doma
 	if err := m.taskService.UpdateDownloadInfo(ctx, task.ID, name, localPath, totalLength); err != nil {
 		logger.Errorf("update download info: %v", err)
 	}
-
-	files := make([]domain.TaskFile, len(t.Files()))
-	for i, file := range t.Files() {
-		files[i] = domain.TaskFile{
-			TaskID: task.ID,
-			Name:   file.DisplayPath(),
-			Path:   file.Path(),
-			Size:   file.Length(),
-			Priority: func() int {
-				if file.Priority() > 0 {
-					return int(file.Priority())
-				}
-				return 1
-			}(),
+	m.cfg.Events.Publish(events.TaskEvent{
+		Type:        events.TypeMetadata,
+		TaskID:      task.ID,
+		UserID:      task.UserID,
+		Status:      task.Status,
+		TorrentName: name,
+		TotalSize:   totalLength,
+	})
+
+	if !sameFiles(task.Files, t.Files()) {
+		previous := make(map[string]int, len(task.Files))
+		for _, file := range task.Files {
+			previous[file.Path] = file.Priority
 		}
-	}
-	if err := m.taskService.ReplaceFiles(ctx, task.ID, files); err != nil {
-		logger.Warnf("replace files: %v", err)
+		files := make([]domain.TaskFile, len(t.Files()))
+		for i, file := range t.Files() {
+			priority, ok := previous[file.Path()]
+			switch {
+			case ok:
+			case len(previous) == 0 && magnet != nil && !magnet.SelectsFile(i):
+				// the so= selection only seeds the first file list
+				priority = domain.FilePrioritySkip
+			default:
+				priority = domain.FilePriorityNormal
+			}
+			files[i] = domain.TaskFile{
+				TaskID:   task.ID,
+				Name:     file.DisplayPath(),
+				Path:     file.Path(),
+				Size:     file.Length(),
+				Priority: priority,
+			}
+		}
+		if err := m.taskService.ReplaceFiles(ctx, task.ID, files); err != nil {
+			logger.Warnf("replace files: %v", err)
+		}
+		task.Files = files
 	}
 
-	t.DownloadAll()
+	applyFilePriorities(t, task.Files)
 
 	lastBytes := int64(0)
 	lastTime := time.Now()
+	// progressBytes and progressAt track the last time the download advanced
+	progressBytes := int64(-1)
+	progressAt := time.Now()
 
 	ticker := time.NewTicker(m.cfg.StatusInterval)
 	defer ticker.Stop()
@@ -303,10 +566,10 @@ func (m *manager) handleTask(ctx context.Context, handle *taskHandle, task *doma
 			logger.Info("task cancelled")
 			return
 		case <-ticker.C:
-			bytesCompleted := t.BytesCompleted()
+			selectedLength, bytesCompleted := selectedProgress(t)
 			progress := 0
-			if totalLength > 0 {
-				progress = int((bytesCompleted * 100) / totalLength)
+			if selectedLength > 0 {
+				progress = int((bytesCompleted * 100) / selectedLength)
 			}
 			elapsed := time.Since(lastTime).Seconds()
 			speed := int64(0)
@@ -315,34 +578,94 @@ func (m *manager) handleTask(ctx context.Context, handle *taskHandle, task *doma
 			}
 			lastBytes = bytesCompleted
 			lastTime = time.Now()
+			task.Progress = progress
+			task.DownloadedBytes = bytesCompleted
 
 			stats := t.Stats()
+			if uploaded := peerUploadedBase + stats.BytesWrittenData.Int64(); uploaded != task.PeerUploaded {
+				task.PeerUploaded = uploaded
+				if err := m.taskService.UpdatePeerUploaded(ctx, task.ID, uploaded); err != nil {
+					logger.Warnf("update peer uploaded: %v", err)
+				}
+			}
 
 			if err := m.taskService.UpdateProgress(ctx, task.ID, progress, speed, bytesCompleted, stats.TotalPeers, stats.ActivePeers, stats.PendingPeers, stats.ConnectedSeeders, stats.HalfOpenPeers); err != nil {
 				logger.Warnf("update progress: %v", err)
 			}
-
-			if progress >= 100 || t.BytesMissing() == 0 {
+			m.cfg.Events.Publish(events.TaskEvent{
+				Type:             events.TypeProgress,
+				TaskID:           task.ID,
+				UserID:           task.UserID,
+				Status:           task.Status,
+				Progress:         progress,
+				Speed:            speed,
+				DownloadedBytes:  bytesCompleted,
+				TotalSize:        selectedLength,
+				TotalPeers:       stats.TotalPeers,
+				ActivePeers:      stats.ActivePeers,
+				PendingPeers:     stats.PendingPeers,
+				ConnectedSeeders: stats.ConnectedSeeders,
+				HalfOpenPeers:    stats.HalfOpenPeers,
+				PeerUploaded:     task.PeerUploaded,
+			})
+
+			if selectedLength > 0 && bytesCompleted >= selectedLength {
 				if err := m.taskService.MarkDownloaded(ctx, task.ID); err != nil {
 					logger.Warnf("mark downloaded: %v", err)
 				}
 				task.Status = domain.TaskStatusDownloaded
+				m.publishStatus(task, task.Status, "")
+				if refreshed, err := m.taskService.GetTask(ctx, task.ID); err == nil {
+					task.Files = refreshed.Files
+				}
 				logger.Info("download completed")
-				m.uploadAndCleanup(ctx, task)
+				m.uploadAndCleanup(ctx, handle, task)
 				return
 			}
+
+			if bytesCompleted != progressBytes {
+				progressBytes = bytesCompleted
+				progressAt = time.Now()
+			} else if m.cfg.StallTimeout > 0 && time.Since(progressAt) >= m.cfg.StallTimeout {
+				m.stallTask(ctx, task, fmt.Sprintf("no download progress for %s", m.cfg.StallTimeout))
+				return
+			}
+		}
+	}
+}
+
+// addTorrent adds the task to the client from its stored metainfo when
+// available, falling back to the magnet link.
+func (m *manager) addTorrent(task *domain.Task, stored []byte) (*torrent.Torrent, error) {
+	if len(stored) > 0 {
+		mi, err := metainfo.Load(bytes.NewReader(stored))
+		if err != nil {
+			return nil, fmt.Errorf("load metainfo: %w", err)
+		}
+		t, err := m.client.AddTorrent(mi)
+		if err != nil {
+			return nil, fmt.Errorf("add torrent: %w", err)
 		}
+		return t, nil
 	}
+
+	t, err := m.client.AddMagnet(task.MagnetURI)
+	if err != nil {
+		return nil, fmt.Errorf("add magnet: %w", err)
+	}
+	return t, nil
 }
 
-func (m *manager) uploadAndCleanup(ctx context.Context, task *domain.Task) {
+func (m *manager) uploadAndCleanup(ctx context.Context, handle *taskHandle, task *domain.Task) {
 	logger := m.cfg.Logger.WithField("task_id", task.ID)
+	seeding := handle.seedPolicy.Load().Enabled()
 
 	if err := m.taskService.UpdateStatus(ctx, task.ID, domain.TaskStatusUploading, nil); err != nil {
 		logger.Errorf("set uploading status: %v", err)
 		return
 	}
 	task.Status = domain.TaskStatusUploading
+	m.publishStatus(task, task.Status, "")
 
 	localPath := task.LocalPath
 	if localPath == "" {
@@ -357,25 +680,33 @@ func (m *manager) uploadAndCleanup(ctx context.Context, task *domain.Task) {
 				task.LocalPath = fallback
 				info = fbInfo
 			} else {
-				m.failTask(ctx, task.ID, fmt.Errorf("local data missing: %w", err))
+				m.failTask(ctx, task, permanent(fmt.Errorf("local data missing: %w", err)))
 				return
 			}
 		} else {
-			m.failTask(ctx, task.ID, fmt.Errorf("local data missing: %w", err))
+			m.failTask(ctx, task, permanent(fmt.Errorf("local data missing: %w", err)))
 			return
 		}
 	}
 
+	// seedPath is where the torrent keeps a single file download while it seeds
+	seedPath := ""
 	if !info.IsDir() {
 		stagingDir := filepath.Join(m.cfg.DownloadRoot, fmt.Sprintf("task-%d", task.ID))
 		if err := os.MkdirAll(stagingDir, 0o755); err != nil {
-			m.failTask(ctx, task.ID, fmt.Errorf("create staging dir: %w", err))
+			m.failTask(ctx, task, fmt.Errorf("create staging dir: %w", err))
 			return
 		}
 		dest := filepath.Join(stagingDir, filepath.Base(localPath))
-		if err := os.Rename(localPath, dest); err != nil {
+		if seeding {
+			if err := copyFile(localPath, dest); err != nil {
+				m.failTask(ctx, task, fmt.Errorf("prepare upload data: %w", err))
+				return
+			}
+			seedPath = localPath
+		} else if err := os.Rename(localPath, dest); err != nil {
 			if copyErr := copyFile(localPath, dest); copyErr != nil {
-				m.failTask(ctx, task.ID, fmt.Errorf("prepare upload data: %w", copyErr))
+				m.failTask(ctx, task, fmt.Errorf("prepare upload data: %w", copyErr))
 				return
 			}
 			if removeErr := os.Remove(localPath); removeErr != nil && !os.IsNotExist(removeErr) {
@@ -392,22 +723,47 @@ func (m *manager) uploadAndCleanup(ctx context.Context, task *domain.Task) {
 	opts := m.cfg.UploadOptions
 	prefix := strings.Trim(opts.KeyPrefix, "/")
 	taskPrefix := fmt.Sprintf("task-%d", task.ID)
+	if task.UserID > 0 {
+		prefix = storage.UserKeyPrefix(prefix, task.UserID)
+	}
 	if prefix == "" {
 		opts.KeyPrefix = taskPrefix
 	} else {
 		opts.KeyPrefix = fmt.Sprintf("%s/%s", prefix, taskPrefix)
 	}
 
+	opts.Files = selectedFileNames(task.Files)
+	opts.State = &taskUploadState{taskID: task.ID, tasks: m.taskService}
+	opts.KeepSource = seeding
+
+	if err := m.taskService.ResetFileUploadStatus(ctx, task.ID); err != nil {
+		logger.Warnf("reset file upload status: %v", err)
+	}
 	progressLogger := newUploadProgressLogger(logger)
+	progressRecorder := m.newUploadProgressRecorder(ctx, task)
 	opts.ProgressCallback = func(done, total int64) {
 		progressLogger(done, total)
+		progressRecorder(done, total)
+	}
+	opts.FileCallback = func(name string, status domain.FileUploadStatus) {
+		if err := m.taskService.SetFileUploadStatus(ctx, task.ID, name, status); err != nil {
+			logger.Warnf("update upload status of %s: %v", name, err)
+		}
+		m.cfg.Events.Publish(events.TaskEvent{
+			Type:             events.TypeFile,
+			TaskID:           task.ID,
+			UserID:           task.UserID,
+			Status:           task.Status,
+			FileName:         name,
+			FileUploadStatus: status,
+		})
 	}
 
 	logger.Infof("upload started from %s", localPath)
 
 	dest, err := m.storage.UploadDirectory(ctx, localPath, opts)
 	if err != nil {
-		m.failTask(ctx, task.ID, fmt.Errorf("upload: %w", err))
+		m.failTask(ctx, task, fmt.Errorf("upload: %w", err))
 		return
 	}
 
@@ -415,7 +771,36 @@ func (m *manager) uploadAndCleanup(ctx context.Context, task *domain.Task) {
 		logger.Errorf("mark uploaded: %v", err)
 		return
 	}
+	if err := m.taskService.UpdateUploadProgress(ctx, task.ID, task.UploadTotalBytes, task.UploadTotalBytes, 0); err != nil {
+		logger.Warnf("update upload progress: %v", err)
+	}
+	if err := m.taskService.ClearUploadState(ctx, task.ID); err != nil {
+		logger.Warnf("clear upload state: %v", err)
+	}
+	task.S3Location = dest
+
+	if seeding {
+		if seedPath != "" {
+			if err := os.RemoveAll(localPath); err != nil {
+				logger.Warnf("remove staging dir: %v", err)
+			}
+			task.LocalPath = seedPath
+			if err := m.taskService.UpdateDownloadInfo(ctx, task.ID, task.TorrentName, seedPath, task.TotalSize); err != nil {
+				logger.Warnf("refresh local path: %v", err)
+			}
+		}
+		m.seed(ctx, handle, task)
+		return
+	}
+
 	task.Status = domain.TaskStatusCompleted
+	m.cfg.Events.Publish(events.TaskEvent{
+		Type:       events.TypeStatus,
+		TaskID:     task.ID,
+		UserID:     task.UserID,
+		Status:     task.Status,
+		S3Location: dest,
+	})
 
 	if err := os.RemoveAll(localPath); err != nil {
 		logger.Warnf("cleanup download dir: %v", err)
@@ -424,12 +809,246 @@ func (m *manager) uploadAndCleanup(ctx context.Context, task *domain.Task) {
 	logger.Infof("task completed and uploaded to %s", dest)
 }
 
-func (m *manager) failTask(ctx context.Context, taskID int64, failErr error) {
+// ApplyFilePriorities pushes the stored file priorities to the running torrent, if any.
+func (m *manager) ApplyFilePriorities(ctx context.Context, taskID int64) error {
+	handle, ok := m.getTaskHandle(taskID)
+	if !ok {
+		return nil
+	}
+	m.mu.Lock()
+	t := handle.torrent
+	m.mu.Unlock()
+	if t == nil || t.Info() == nil {
+		return nil
+	}
+
+	task, err := m.taskService.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	applyFilePriorities(t, task.Files)
+	return nil
+}
+
+// ApplyTrackers adds the trackers of the stored magnet URI to the running torrent, if any.
+func (m *manager) ApplyTrackers(ctx context.Context, taskID int64) error {
+	handle, ok := m.getTaskHandle(taskID)
+	if !ok {
+		return nil
+	}
+	m.mu.Lock()
+	t := handle.torrent
+	m.mu.Unlock()
+	if t == nil {
+		return nil
+	}
+
+	task, err := m.taskService.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	addTrackers(t, domain.MagnetTrackers(task.MagnetURI))
+	return nil
+}
+
+// OpenStream returns a reader over a file of an actively downloading task.
+// The reader is bound to ctx so pending reads are abandoned once it is done.
+func (m *manager) OpenStream(ctx context.Context, taskID int64, filePath string) (*FileStream, error) {
+	handle, ok := m.getTaskHandle(taskID)
+	if !ok {
+		return nil, fmt.Errorf("%w: task is not active", ErrStreamUnavailable)
+	}
+	m.mu.Lock()
+	t := handle.torrent
+	m.mu.Unlock()
+	if t == nil || t.Info() == nil {
+		return nil, fmt.Errorf("%w: torrent metadata not available yet", ErrStreamUnavailable)
+	}
+
+	for _, file := range t.Files() {
+		if file.Path() != filePath {
+			continue
+		}
+		reader := file.NewReader()
+		reader.SetContext(ctx)
+		reader.SetReadahead(m.cfg.StreamReadahead)
+		reader.SetResponsive()
+		return &FileStream{
+			ReadSeekCloser: reader,
+			Name:           file.DisplayPath(),
+			Size:           file.Length(),
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: file %s not found in torrent", ErrStreamUnavailable, filePath)
+}
+
+func addTrackers(t *torrent.Torrent, trackers []string) {
+	for _, tracker := range trackers {
+		t.AddTrackers([][]string{{tracker}})
+	}
+}
+
+// addMagnetSources adds the web seeds and x.pe peers of a magnet link.
+func addMagnetSources(t *torrent.Torrent, magnet *domain.Magnet) {
+	if len(magnet.WebSeeds) > 0 {
+		t.AddWebSeeds(magnet.WebSeeds)
+	}
+	peers := make([]torrent.PeerInfo, len(magnet.Peers))
+	for i, addr := range magnet.Peers {
+		peers[i] = torrent.PeerInfo{
+			Addr:    torrent.StringAddr(addr),
+			Source:  torrent.PeerSourceDirect,
+			Trusted: true,
+		}
+	}
+	t.AddPeers(peers)
+}
+
+func applyFilePriorities(t *torrent.Torrent, files []domain.TaskFile) {
+	priorities := make(map[string]int, len(files))
+	for _, file := range files {
+		priorities[file.Path] = file.Priority
+	}
+	for _, file := range t.Files() {
+		priority, ok := priorities[file.Path()]
+		if !ok {
+			priority = domain.FilePriorityNormal
+		}
+		switch priority {
+		case domain.FilePrioritySkip:
+			file.SetPriority(torrent.PiecePriorityNone)
+		case domain.FilePriorityHigh:
+			file.SetPriority(torrent.PiecePriorityHigh)
+		default:
+			file.SetPriority(torrent.PiecePriorityNormal)
+		}
+	}
+}
+
+// selectedProgress sums the length and completed bytes of the files that are not skipped.
+func selectedProgress(t *torrent.Torrent) (length, completed int64) {
+	for _, file := range t.Files() {
+		if file.Priority() == torrent.PiecePriorityNone {
+			continue
+		}
+		length += file.Length()
+		completed += file.BytesCompleted()
+	}
+	return length, completed
+}
+
+func sameFiles(known []domain.TaskFile, files []*torrent.File) bool {
+	if len(known) == 0 || len(known) != len(files) {
+		return false
+	}
+	for i, file := range files {
+		if known[i].Path != file.Path() || known[i].Size != file.Length() {
+			return false
+		}
+	}
+	return true
+}
+
+// selectedFileNames returns the upload paths of selected files, or nil when
+// every file is selected and the whole directory should be uploaded.
+func selectedFileNames(files []domain.TaskFile) []string {
+	var names []string
+	skipped := false
+	for _, file := range files {
+		if !file.Selected() {
+			skipped = true
+			continue
+		}
+		names = append(names, file.Name)
+	}
+	if !skipped {
+		return nil
+	}
+	return names
+}
+
+// taskUploadState records upload progress of a task through the task service.
+type taskUploadState struct {
+	taskID int64
+	tasks  service.TaskService
+}
+
+func (s *taskUploadState) Get(ctx context.Context, key string) (*domain.UploadObject, error) {
+	return s.tasks.GetUploadObject(ctx, s.taskID, key)
+}
+
+func (s *taskUploadState) Save(ctx context.Context, obj *domain.UploadObject) error {
+	obj.TaskID = s.taskID
+	return s.tasks.SaveUploadObject(ctx, obj)
+}
+
+func (s *taskUploadState) AddPart(ctx context.Context, key string, part domain.UploadPart) error {
+	return s.tasks.AddUploadPart(ctx, s.taskID, key, part)
+}
+
+// failTask marks the task failed and schedules an automatic retry when the
+// error is retriable and the retry policy allows another attempt.
+func (m *manager) failTask(ctx context.Context, task *domain.Task, failErr error) {
+	logger := m.cfg.Logger.WithField("task_id", task.ID)
 	msg := failErr.Error()
-	if err := m.taskService.UpdateStatus(ctx, taskID, domain.TaskStatusFailed, &msg); err != nil {
-		m.cfg.Logger.WithField("task_id", taskID).Errorf("persist failure status: %v", err)
+	attempts := task.Attempts + 1
+	var nextRetryAt *time.Time
+	if retriable(failErr) && m.cfg.Retry.ShouldRetry(attempts) {
+		at := time.Now().Add(m.cfg.Retry.Delay(attempts))
+		nextRetryAt = &at
+	}
+
+	if err := m.taskService.UpdateStatus(ctx, task.ID, domain.TaskStatusFailed, &msg); err != nil {
+		logger.Errorf("persist failure status: %v", err)
+	}
+	if err := m.taskService.UpdateRetry(ctx, task.ID, attempts, nextRetryAt); err != nil {
+		logger.Errorf("persist retry: %v", err)
+	}
+	task.Status = domain.TaskStatusFailed
+	task.ErrorMessage = msg
+	task.Attempts = attempts
+	task.NextRetryAt = nextRetryAt
+
+	m.cfg.Events.Publish(events.TaskEvent{
+		Type:         events.TypeStatus,
+		TaskID:       task.ID,
+		UserID:       task.UserID,
+		Status:       domain.TaskStatusFailed,
+		ErrorMessage: msg,
+		Attempts:     attempts,
+		NextRetryAt:  nextRetryAt,
+	})
+	if nextRetryAt != nil {
+		logger.Errorf("%s (attempt %d, retrying at %s)", msg, attempts, nextRetryAt.Format(time.RFC3339))
+		return
 	}
-	m.cfg.Logger.WithField("task_id", taskID).Error(msg)
+	logger.Error(msg)
+}
+
+// stallTask parks a download that is going nowhere. Returning from
+// handleTask afterwards drops the torrent and frees its slot.
+func (m *manager) stallTask(ctx context.Context, task *domain.Task, reason string) {
+	logger := m.cfg.Logger.WithField("task_id", task.ID)
+	if err := m.taskService.UpdateStatus(ctx, task.ID, domain.TaskStatusStalled, &reason); err != nil {
+		logger.Errorf("persist stalled status: %v", err)
+	}
+	if err := m.taskService.UpdateProgress(ctx, task.ID, task.Progress, 0, task.DownloadedBytes, 0, 0, 0, 0, 0); err != nil {
+		logger.Warnf("reset progress stats: %v", err)
+	}
+	task.Status = domain.TaskStatusStalled
+	task.ErrorMessage = reason
+	m.publishStatus(task, task.Status, reason)
+	logger.Warnf("task stalled: %s", reason)
+}
+
+func (m *manager) publishStatus(task *domain.Task, status domain.TaskStatus, errMsg string) {
+	m.cfg.Events.Publish(events.TaskEvent{
+		Type:         events.TypeStatus,
+		TaskID:       task.ID,
+		UserID:       task.UserID,
+		Status:       status,
+		ErrorMessage: errMsg,
+	})
 }
 
 func infoHashToDir(hash metainfo.Hash) string {
@@ -467,6 +1086,46 @@ func copyFile(src, dst string) error {
 	return nil
 }
 
+// newUploadProgressRecorder persists and publishes upload progress at most
+// once per status interval, deriving the speed between recordings.
+func (m *manager) newUploadProgressRecorder(ctx context.Context, task *domain.Task) func(done, total int64) {
+	var (
+		lastSave time.Time
+		lastDone int64
+	)
+	return func(done, total int64) {
+		now := time.Now()
+		if now.Sub(lastSave) < m.cfg.StatusInterval && done != total {
+			return
+		}
+		speed := int64(0)
+		if !lastSave.IsZero() {
+			if elapsed := now.Sub(lastSave).Seconds(); elapsed > 0 {
+				speed = int64(float64(done-lastDone) / elapsed)
+			}
+		}
+		lastSave, lastDone = now, done
+
+		task.UploadDoneBytes = done
+		task.UploadTotalBytes = total
+		task.UploadSpeed = speed
+		if err := m.taskService.UpdateUploadProgress(ctx, task.ID, done, total, speed); err != nil {
+			m.cfg.Logger.WithField("task_id", task.ID).Warnf("update upload progress: %v", err)
+		}
+		m.cfg.Events.Publish(events.TaskEvent{
+			Type:             events.TypeProgress,
+			TaskID:           task.ID,
+			UserID:           task.UserID,
+			Status:           task.Status,
+			Progress:         100,
+			TotalSize:        task.TotalSize,
+			UploadDoneBytes:  done,
+			UploadTotalBytes: total,
+			UploadSpeed:      speed,
+		})
+	}
+}
+
 func newUploadProgressLogger(logger *logrus.Entry) func(done, total int64) {
 	var (
 		lastLog time.Time
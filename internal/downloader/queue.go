@@ -0,0 +1,159 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+)
+
+// MaxActive returns the number of tasks allowed to download at once.
+func (m *manager) MaxActive() int {
+	return m.queue.limit()
+}
+
+// SetMaxActive changes the number of tasks allowed to download at once.
+func (m *manager) SetMaxActive(n int) {
+	m.queue.setMaxActive(n)
+	m.cfg.Logger.Infof("max active downloads set to %d", n)
+}
+
+// ApplyQueueOrder pushes the stored priorities and positions of waiting
+// tasks to the queue.
+func (m *manager) ApplyQueueOrder(ctx context.Context) error {
+	for _, id := range m.queue.waitingIDs() {
+		task, err := m.taskService.GetTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		m.queue.reorder(id, task.QueuePriority, task.QueuePosition)
+	}
+	return nil
+}
+
+// downloadQueue hands out download slots to waiting tasks in queue order:
+// highest priority first, then lowest queue position.
+type downloadQueue struct {
+	mu        sync.Mutex
+	maxActive int
+	active    int
+	waiting   []*queueEntry
+}
+
+type queueEntry struct {
+	taskID   int64
+	priority int
+	position int64
+	ready    chan struct{}
+	granted  bool
+}
+
+func (e *queueEntry) before(other *queueEntry) bool {
+	if e.priority != other.priority {
+		return e.priority > other.priority
+	}
+	return e.position < other.position
+}
+
+func newDownloadQueue(maxActive int) *downloadQueue {
+	return &downloadQueue{maxActive: maxActive}
+}
+
+// acquire waits for a slot and returns the function that gives it back.
+func (q *downloadQueue) acquire(ctx context.Context, taskID int64, priority int, position int64) (func(), error) {
+	entry := &queueEntry{
+		taskID:   taskID,
+		priority: priority,
+		position: position,
+		ready:    make(chan struct{}),
+	}
+	q.mu.Lock()
+	q.waiting = append(q.waiting, entry)
+	q.dispatchLocked()
+	q.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		q.mu.Lock()
+		granted := entry.granted
+		if !granted {
+			q.removeLocked(entry)
+		}
+		q.mu.Unlock()
+		if granted {
+			q.release()
+		}
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() { once.Do(q.release) }, nil
+}
+
+func (q *downloadQueue) release() {
+	q.mu.Lock()
+	q.active--
+	q.dispatchLocked()
+	q.mu.Unlock()
+}
+
+// setMaxActive changes the number of slots. Lowering it lets running tasks
+// finish rather than interrupting them.
+func (q *downloadQueue) setMaxActive(n int) {
+	q.mu.Lock()
+	q.maxActive = n
+	q.dispatchLocked()
+	q.mu.Unlock()
+}
+
+func (q *downloadQueue) limit() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.maxActive
+}
+
+// waitingIDs returns the tasks currently waiting for a slot.
+func (q *downloadQueue) waitingIDs() []int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ids := make([]int64, len(q.waiting))
+	for i, entry := range q.waiting {
+		ids[i] = entry.taskID
+	}
+	return ids
+}
+
+// reorder updates the queue order of a waiting task.
+func (q *downloadQueue) reorder(taskID int64, priority int, position int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, entry := range q.waiting {
+		if entry.taskID == taskID {
+			entry.priority = priority
+			entry.position = position
+		}
+	}
+}
+
+func (q *downloadQueue) dispatchLocked() {
+	for q.active < q.maxActive && len(q.waiting) > 0 {
+		next := q.waiting[0]
+		for _, entry := range q.waiting[1:] {
+			if entry.before(next) {
+				next = entry
+			}
+		}
+		q.removeLocked(next)
+		next.granted = true
+		q.active++
+		close(next.ready)
+	}
+}
+
+func (q *downloadQueue) removeLocked(entry *queueEntry) {
+	for i, e := range q.waiting {
+		if e == entry {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return
+		}
+	}
+}
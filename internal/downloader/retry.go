@@ -0,0 +1,142 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/storage"
+)
+
+// retryScheduleInterval is how often failed tasks are checked for a due retry.
+const retryScheduleInterval = 10 * time.Second
+
+// permanentError marks a task failure that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+func permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// transientError marks a torrent failure that may clear up once trackers or
+// peers respond, such as torrent info that went missing.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+func transient(err error) error {
+	return &transientError{err: err}
+}
+
+// retriable reports whether a task failure may go away on its own. Only
+// these classes are retried:
+//   - torrent failures marked transient
+//   - network errors, including tracker, peer and storage timeouts
+//   - storage responses with a 5xx status or a throttling code
+//
+// Any other failure, including an unknown one, leaves the task failed.
+// Downloads that stall are not failed at all but parked by stallTask.
+func retriable(err error) bool {
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return false
+	}
+	var temp *transientError
+	if errors.As(err, &temp) {
+		return true
+	}
+	return storage.IsTransient(err)
+}
+
+// Retry puts a failed task back into the queue right away and resets its
+// attempt count.
+func (m *manager) Retry(ctx context.Context, taskID int64) error {
+	task, err := m.taskService.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	if task.Status != domain.TaskStatusFailed {
+		return fmt.Errorf("%w: cannot retry %s task", ErrInvalidTaskState, task.Status)
+	}
+	if _, ok := m.getTaskHandle(taskID); ok {
+		return nil
+	}
+
+	if err := m.taskService.UpdateRetry(ctx, taskID, 0, nil); err != nil {
+		return err
+	}
+	task.Attempts = 0
+	task.NextRetryAt = nil
+	if err := m.requeue(ctx, task); err != nil {
+		return err
+	}
+	m.cfg.Logger.WithField("task_id", taskID).Info("task retried")
+	return nil
+}
+
+// requeue spawns a failed task again. Tasks that finished downloading go
+// straight back to the upload.
+func (m *manager) requeue(ctx context.Context, task *domain.Task) error {
+	status := domain.TaskStatusPending
+	if task.DownloadedAt != nil {
+		status = domain.TaskStatusDownloaded
+	}
+	if err := m.taskService.UpdateStatus(ctx, task.ID, status, nil); err != nil {
+		return err
+	}
+	task.Status = status
+	task.ErrorMessage = ""
+	m.publishStatus(task, status, "")
+	m.spawnTask(*task)
+	return nil
+}
+
+func (m *manager) runRetryScheduler() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(retryScheduleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.retryDueTasks(m.ctx)
+		}
+	}
+}
+
+// retryDueTasks re-enqueues failed tasks whose backoff has elapsed.
+func (m *manager) retryDueTasks(ctx context.Context) {
+	tasks, err := m.taskService.ListDueRetries(ctx)
+	if err != nil {
+		m.cfg.Logger.Warnf("list due retries: %v", err)
+		return
+	}
+	for i := range tasks {
+		task := &tasks[i]
+		logger := m.cfg.Logger.WithField("task_id", task.ID)
+		if _, ok := m.getTaskHandle(task.ID); ok {
+			continue
+		}
+		if err := m.taskService.UpdateRetry(ctx, task.ID, task.Attempts, nil); err != nil {
+			logger.Warnf("clear retry: %v", err)
+			continue
+		}
+		task.NextRetryAt = nil
+		if err := m.requeue(ctx, task); err != nil {
+			logger.Warnf("requeue task: %v", err)
+			continue
+		}
+		logger.Infof("retrying task after %d failed attempts", task.Attempts)
+	}
+}
@@ -0,0 +1,73 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+
+	"magnet-player/internal/storage"
+)
+
+// timeoutError is a net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func responseError(status int) error {
+	return &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+		Err:      errors.New("response error"),
+	}
+}
+
+func TestRetriable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unknown error", errors.New("boom"), false},
+		{"local data missing", permanent(fmt.Errorf("local data missing: %w", os.ErrNotExist)), false},
+		{"permanent wins over transient cause", permanent(fmt.Errorf("upload: %w", context.DeadlineExceeded)), false},
+		{"missing torrent info", transient(errors.New("missing torrent info")), true},
+		{"cancelled", fmt.Errorf("upload: %w", context.Canceled), false},
+		{"permission denied", fmt.Errorf("upload: %w", fs.ErrPermission), false},
+
+		{"deadline exceeded", fmt.Errorf("upload: %w", context.DeadlineExceeded), true},
+		{"net timeout", fmt.Errorf("announce: %w", timeoutError{}), true},
+		{"connection refused", fmt.Errorf("upload: %w", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), true},
+		{"connection reset", fmt.Errorf("upload: %w", syscall.ECONNRESET), true},
+		{"unexpected eof", fmt.Errorf("upload: %w", io.ErrUnexpectedEOF), true},
+
+		{"s3 slow down", fmt.Errorf("upload: %w", &smithy.GenericAPIError{Code: "SlowDown"}), true},
+		{"s3 internal error", fmt.Errorf("upload: %w", &smithy.GenericAPIError{Code: "InternalError"}), true},
+		{"s3 access denied", fmt.Errorf("upload: %w", &smithy.GenericAPIError{Code: "AccessDenied"}), false},
+		{"s3 no such bucket", fmt.Errorf("upload: %w", &smithy.GenericAPIError{Code: "NoSuchBucket"}), false},
+		{"http 503", fmt.Errorf("upload: %w", responseError(http.StatusServiceUnavailable)), true},
+		{"http 429", fmt.Errorf("upload: %w", responseError(http.StatusTooManyRequests)), true},
+		{"http 403", fmt.Errorf("upload: %w", responseError(http.StatusForbidden)), false},
+		{"webdav 502", fmt.Errorf("upload: %w", &storage.WebDAVStatusError{Op: "put", StatusCode: http.StatusBadGateway}), true},
+		{"webdav 507", fmt.Errorf("upload: %w", &storage.WebDAVStatusError{Op: "put", StatusCode: http.StatusInsufficientStorage}), true},
+		{"webdav 401", fmt.Errorf("upload: %w", &storage.WebDAVStatusError{Op: "put", StatusCode: http.StatusUnauthorized}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retriable(tt.err); got != tt.want {
+				t.Errorf("retriable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
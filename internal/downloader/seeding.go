@@ -0,0 +1,159 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/anacrolix/torrent"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/events"
+)
+
+// ApplySeedingPolicy pushes the stored seeding policy to the running task, if any.
+func (m *manager) ApplySeedingPolicy(ctx context.Context, taskID int64) error {
+	handle, ok := m.getTaskHandle(taskID)
+	if !ok {
+		return nil
+	}
+	task, err := m.taskService.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	policy := m.seedingPolicy(task)
+	handle.seedPolicy.Store(&policy)
+	return nil
+}
+
+// seedingPolicy returns the task's own policy or the configured default.
+func (m *manager) seedingPolicy(task *domain.Task) domain.SeedingPolicy {
+	if task.SeedPolicy != nil {
+		return *task.SeedPolicy
+	}
+	return m.cfg.Seeding
+}
+
+// seed keeps an uploaded task available to peers until its seeding policy is
+// satisfied, then removes the local data. It reuses the task's torrent when
+// the download just finished and adds it from the stored metainfo otherwise.
+func (m *manager) seed(ctx context.Context, handle *taskHandle, task *domain.Task) {
+	logger := m.cfg.Logger.WithField("task_id", task.ID)
+
+	// seeding must not hold up queued downloads
+	if handle.releaseSlot != nil {
+		handle.releaseSlot()
+	}
+
+	t, base := m.taskTorrent(task.ID)
+	if t == nil {
+		stored, err := m.taskService.GetMetainfo(ctx, task.ID)
+		if err != nil || len(stored) == 0 {
+			logger.Warnf("metainfo unavailable, skipping seeding: %v", err)
+			m.finishSeeding(ctx, task)
+			return
+		}
+		t, err = m.addTorrent(task, stored)
+		if err != nil {
+			logger.Warnf("skipping seeding: %v", err)
+			m.finishSeeding(ctx, task)
+			return
+		}
+		defer t.Drop()
+		base = task.PeerUploaded
+		m.setTaskTorrent(task.ID, t, base)
+
+		throttleCtx, stopThrottle := context.WithCancel(ctx)
+		defer stopThrottle()
+		go handle.throttle.run(throttleCtx, t)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.GotInfo():
+		}
+		applyFilePriorities(t, task.Files)
+	}
+
+	if err := m.taskService.MarkSeeding(ctx, task.ID); err != nil {
+		logger.Errorf("set seeding status: %v", err)
+		return
+	}
+	if task.SeedingStartedAt == nil {
+		now := time.Now()
+		task.SeedingStartedAt = &now
+	}
+	task.Status = domain.TaskStatusSeeding
+	m.publishStatus(task, task.Status, "")
+	logger.Info("seeding started")
+
+	ticker := time.NewTicker(m.cfg.StatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("seeding interrupted")
+			return
+		case <-ticker.C:
+		}
+
+		stats := t.Stats()
+		if uploaded := base + stats.BytesWrittenData.Int64(); uploaded != task.PeerUploaded {
+			task.PeerUploaded = uploaded
+			if err := m.taskService.UpdatePeerUploaded(ctx, task.ID, uploaded); err != nil {
+				logger.Warnf("update peer uploaded: %v", err)
+			}
+			m.cfg.Events.Publish(events.TaskEvent{
+				Type:         events.TypeProgress,
+				TaskID:       task.ID,
+				UserID:       task.UserID,
+				Status:       task.Status,
+				Progress:     100,
+				TotalSize:    task.TotalSize,
+				PeerUploaded: uploaded,
+			})
+		}
+
+		if handle.seedPolicy.Load().Satisfied(task.Ratio(), time.Since(*task.SeedingStartedAt)) {
+			break
+		}
+	}
+
+	logger.Infof("seeding finished at ratio %.2f", task.Ratio())
+	t.Drop()
+	m.finishSeeding(ctx, task)
+}
+
+// finishSeeding completes the task and removes its local data.
+func (m *manager) finishSeeding(ctx context.Context, task *domain.Task) {
+	logger := m.cfg.Logger.WithField("task_id", task.ID)
+	if err := m.taskService.UpdateStatus(ctx, task.ID, domain.TaskStatusCompleted, nil); err != nil {
+		logger.Errorf("set completed status: %v", err)
+		return
+	}
+	task.Status = domain.TaskStatusCompleted
+	m.cfg.Events.Publish(events.TaskEvent{
+		Type:       events.TypeStatus,
+		TaskID:     task.ID,
+		UserID:     task.UserID,
+		Status:     task.Status,
+		S3Location: task.S3Location,
+	})
+
+	if task.LocalPath != "" {
+		if err := os.RemoveAll(task.LocalPath); err != nil {
+			logger.Warnf("cleanup download dir: %v", err)
+		}
+	}
+}
+
+// taskTorrent returns the running torrent of a task and the task's peer upload
+// total from before the torrent was added.
+func (m *manager) taskTorrent(id int64) (*torrent.Torrent, int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if handle, ok := m.active[id]; ok {
+		return handle.torrent, handle.peerUploadedBase
+	}
+	return nil, 0
+}
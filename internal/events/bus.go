@@ -0,0 +1,166 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"magnet-player/internal/domain"
+)
+
+// Event types published for tasks.
+const (
+	TypeCreated  = "created"
+	TypeStatus   = "status"
+	TypeMetadata = "metadata"
+	TypeProgress = "progress"
+	TypeDeleted  = "deleted"
+	// TypeFile reports the upload status of one file of a task.
+	TypeFile = "file"
+	// TypeResync tells a subscriber that events were lost and it should reload its state.
+	TypeResync = "resync"
+)
+
+// TaskEvent describes a change to a task. Only the fields relevant to the
+// event type are populated.
+type TaskEvent struct {
+	ID               uint64
+	Type             string
+	TaskID           int64
+	UserID           int64
+	Status           domain.TaskStatus
+	Progress         int
+	Speed            int64
+	DownloadedBytes  int64
+	TotalSize        int64
+	TotalPeers       int
+	ActivePeers      int
+	PendingPeers     int
+	ConnectedSeeders int
+	HalfOpenPeers    int
+	PeerUploaded     int64
+	UploadDoneBytes  int64
+	UploadTotalBytes int64
+	UploadSpeed      int64
+	FileName         string
+	FileUploadStatus domain.FileUploadStatus
+	TorrentName      string
+	S3Location       string
+	ErrorMessage     string
+	// Attempts and NextRetryAt describe the retry state of a failed task.
+	Attempts    int
+	NextRetryAt *time.Time
+	Time        time.Time
+}
+
+// Bus fans task events out to in-process subscribers and keeps a bounded
+// history so reconnecting clients can catch up from their last event ID.
+// Event IDs continue from the time the bus was created, in microseconds, so
+// an ID handed out before a restart is always older than the new history.
+type Bus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []TaskEvent
+	historySize int
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives events matching its filter until it is closed. The
+// channel is closed when the subscriber falls too far behind.
+type Subscription struct {
+	C      <-chan TaskEvent
+	ch     chan TaskEvent
+	filter func(TaskEvent) bool
+	bus    *Bus
+}
+
+func NewBus(historySize int) *Bus {
+	if historySize <= 0 {
+		historySize = 1024
+	}
+	return &Bus{
+		nextID:      uint64(time.Now().UnixMicro()),
+		historySize: historySize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the event an ID and delivers it to matching subscribers.
+// It is safe to call on a nil Bus.
+func (b *Bus) Publish(evt TaskEvent) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	evt.ID = b.nextID
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+
+	b.history = append(b.history, evt)
+	if len(b.history) > b.historySize {
+		b.history = append(b.history[:0:0], b.history[len(b.history)-b.historySize:]...)
+	}
+
+	for sub := range b.subscribers {
+		if sub.filter != nil && !sub.filter(evt) {
+			continue
+		}
+		select {
+		case sub.ch <- evt:
+		default:
+			// slow consumer: drop it so it reconnects and replays from history
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribe registers a subscriber and returns the matching events published
+// after lastID. When lastID is older than the retained history or was not
+// issued by this bus, the backlog starts with a resync event.
+func (b *Bus) Subscribe(lastID uint64, filter func(TaskEvent) bool) (*Subscription, []TaskEvent) {
+	ch := make(chan TaskEvent, 64)
+	sub := &Subscription{
+		C:      ch,
+		ch:     ch,
+		filter: filter,
+		bus:    b,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []TaskEvent
+	if lastID > 0 && lastID != b.nextID {
+		if lastID > b.nextID || len(b.history) == 0 || b.history[0].ID > lastID+1 {
+			backlog = append(backlog, TaskEvent{ID: b.nextID, Type: TypeResync, Time: time.Now()})
+		} else {
+			for _, evt := range b.history {
+				if evt.ID <= lastID {
+					continue
+				}
+				if filter != nil && !filter(evt) {
+					continue
+				}
+				backlog = append(backlog, evt)
+			}
+		}
+	}
+
+	b.subscribers[sub] = struct{}{}
+	return sub, backlog
+}
+
+// Close unregisters the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subscribers[s]; ok {
+		delete(s.bus.subscribers, s)
+		close(s.ch)
+	}
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+// publish sends n events for user 1 and returns the ID of the last one.
+func publish(b *Bus, n int) uint64 {
+	for range n {
+		b.Publish(TaskEvent{Type: TypeProgress, UserID: 1})
+	}
+	return b.nextID
+}
+
+func types(backlog []TaskEvent) []string {
+	var got []string
+	for _, evt := range backlog {
+		got = append(got, evt.Type)
+	}
+	return got
+}
+
+func TestSubscribeBacklog(t *testing.T) {
+	b := NewBus(4)
+	first := publish(b, 1)
+	publish(b, 5)
+	last := b.nextID
+
+	tests := []struct {
+		name   string
+		lastID uint64
+		want   int
+		resync bool
+	}{
+		{"no cursor", 0, 0, false},
+		{"up to date", last, 0, false},
+		{"inside the history", last - 2, 2, false},
+		{"just before the history", last - 4, 4, false},
+		{"older than the history", first, 1, true},
+		{"ahead of the bus", last + 10, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, backlog := b.Subscribe(tt.lastID, nil)
+			defer sub.Close()
+			if len(backlog) != tt.want {
+				t.Fatalf("backlog = %v, want %d events", types(backlog), tt.want)
+			}
+			if tt.resync {
+				if backlog[0].Type != TypeResync {
+					t.Errorf("backlog = %v, want a resync", types(backlog))
+				}
+				return
+			}
+			for i, evt := range backlog {
+				if want := tt.lastID + uint64(i) + 1; evt.ID != want {
+					t.Errorf("backlog[%d].ID = %d, want %d", i, evt.ID, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSubscribeAfterRestart(t *testing.T) {
+	old := NewBus(16)
+	cursor := publish(old, 3)
+	time.Sleep(time.Millisecond)
+
+	// a restarted process has no history yet
+	restarted := NewBus(16)
+	sub, backlog := restarted.Subscribe(cursor, nil)
+	sub.Close()
+	if len(backlog) != 1 || backlog[0].Type != TypeResync {
+		t.Errorf("backlog before any event = %v, want a resync", types(backlog))
+	}
+
+	// and once it has, the old cursor still predates all of it
+	publish(restarted, 2)
+	sub, backlog = restarted.Subscribe(cursor, nil)
+	sub.Close()
+	if len(backlog) != 1 || backlog[0].Type != TypeResync {
+		t.Errorf("backlog = %v, want a resync", types(backlog))
+	}
+	if backlog[0].ID != restarted.nextID {
+		t.Errorf("resync ID = %d, want %d", backlog[0].ID, restarted.nextID)
+	}
+}
+
+func TestSubscribeFilter(t *testing.T) {
+	b := NewBus(16)
+	cursor := publish(b, 1)
+	b.Publish(TaskEvent{Type: TypeStatus, UserID: 2})
+	b.Publish(TaskEvent{Type: TypeDeleted, UserID: 1})
+
+	sub, backlog := b.Subscribe(cursor, func(evt TaskEvent) bool { return evt.UserID == 1 })
+	defer sub.Close()
+	if len(backlog) != 1 || backlog[0].Type != TypeDeleted {
+		t.Errorf("backlog = %v, want only the deleted event", types(backlog))
+	}
+
+	b.Publish(TaskEvent{Type: TypeStatus, UserID: 2})
+	b.Publish(TaskEvent{Type: TypeCreated, UserID: 1})
+	if evt := <-sub.C; evt.Type != TypeCreated {
+		t.Errorf("live event = %s, want created", evt.Type)
+	}
+}
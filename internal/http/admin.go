@@ -0,0 +1,179 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/service"
+)
+
+type updateUserRequest struct {
+	Role     *domain.UserRole `json:"role"`
+	Disabled *bool            `json:"disabled"`
+}
+
+type resetPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+func (h *Handler) listUsers(c *gin.Context) {
+	users, err := h.users.ListUsers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]UserResponse, len(users))
+	for i := range users {
+		resp[i] = userToResponse(users[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) updateUser(c *gin.Context) {
+	id, ok := h.adminTargetID(c, false)
+	if !ok {
+		return
+	}
+
+	var req updateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role == nil && req.Disabled == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role or disabled is required"})
+		return
+	}
+
+	var (
+		user *domain.User
+		err  error
+	)
+	if req.Role != nil {
+		if user, err = h.users.SetRole(c.Request.Context(), id, *req.Role); err != nil {
+			writeUserError(c, err)
+			return
+		}
+	}
+	if req.Disabled != nil {
+		if user, err = h.users.SetDisabled(c.Request.Context(), id, *req.Disabled); err != nil {
+			writeUserError(c, err)
+			return
+		}
+		if user.Disabled && h.sessions != nil {
+			if err := h.sessions.RevokeAll(c.Request.Context(), id); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, userToResponse(*user))
+}
+
+func (h *Handler) resetUserPassword(c *gin.Context) {
+	id, ok := h.adminTargetID(c, true)
+	if !ok {
+		return
+	}
+
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.users.ResetPassword(c.Request.Context(), id, req.Password); err != nil {
+		writeUserError(c, err)
+		return
+	}
+	if h.sessions != nil {
+		if err := h.sessions.RevokeAll(c.Request.Context(), id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) deleteUser(c *gin.Context) {
+	id, ok := h.adminTargetID(c, false)
+	if !ok {
+		return
+	}
+	deleteRemote, err := strconv.ParseBool(c.DefaultQuery("delete_remote", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid flag delete_remote"})
+		return
+	}
+	if deleteRemote && h.storage == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "storage service not configured"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.users.GetByID(ctx, id); err != nil {
+		writeUserError(c, err)
+		return
+	}
+
+	// the user's tasks go first, the same way a bulk delete removes them
+	tasks, err := h.tasks.ListTasks(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	results, err := h.bulkDelete(ctx, tasks, deleteRemote)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, result := range results {
+		if result.Status == batchFailed {
+			c.JSON(http.StatusConflict, gin.H{"error": "some of the user's tasks could not be deleted", "tasks": results})
+			return
+		}
+	}
+
+	if err := h.users.DeleteUser(ctx, id); err != nil {
+		writeUserError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": id, "tasks": results})
+}
+
+// adminTargetID parses the :id parameter. Unless allowSelf is set it refuses
+// to target the caller's own account so an admin cannot lock themselves out.
+func (h *Handler) adminTargetID(c *gin.Context, allowSelf bool) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return 0, false
+	}
+	if current, ok := userFromContext(c); ok && current.ID == id && !allowSelf {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot modify your own account"})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeUserError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, service.ErrLastAdmin), errors.Is(err, service.ErrUserHasTasks):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case strings.Contains(strings.ToLower(err.Error()), "required"),
+		strings.Contains(strings.ToLower(err.Error()), "must be"),
+		strings.Contains(strings.ToLower(err.Error()), "invalid"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -2,13 +2,14 @@ package http
 
 import (
 	"context"
-	"encoding/base32"
-	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
-	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -19,6 +20,7 @@ import (
 
 	"magnet-player/internal/domain"
 	"magnet-player/internal/downloader"
+	"magnet-player/internal/events"
 	"magnet-player/internal/service"
 	"magnet-player/internal/storage"
 )
@@ -27,25 +29,33 @@ import (
 type Handler struct {
 	tasks     service.TaskService
 	users     service.UserService
+	sessions  service.SessionService
+	settings  service.SettingsService
 	manager   downloader.Manager
+	events    *events.Bus
 	storage   storage.Service
 	bucket    string
+	keyPrefix string
 	dataRoot  string
 	jwtSecret []byte
 	tokenTTL  time.Duration
 }
 
-func NewHandler(tasks service.TaskService, manager downloader.Manager, store storage.Service, bucket, dataRoot string, users service.UserService, jwtSecret string, tokenTTL time.Duration) *Handler {
+func NewHandler(tasks service.TaskService, manager downloader.Manager, bus *events.Bus, store storage.Service, bucket, keyPrefix, dataRoot string, users service.UserService, sessions service.SessionService, settings service.SettingsService, jwtSecret string, tokenTTL time.Duration) *Handler {
 	secret := strings.TrimSpace(jwtSecret)
 	if tokenTTL <= 0 {
-		tokenTTL = 24 * time.Hour
+		tokenTTL = 15 * time.Minute
 	}
 	return &Handler{
 		tasks:     tasks,
 		users:     users,
+		sessions:  sessions,
+		settings:  settings,
 		manager:   manager,
+		events:    bus,
 		storage:   store,
 		bucket:    bucket,
+		keyPrefix: keyPrefix,
 		dataRoot:  dataRoot,
 		jwtSecret: []byte(secret),
 		tokenTTL:  tokenTTL,
@@ -60,19 +70,62 @@ func (h *Handler) RegisterRoutes(router *gin.Engine) {
 	{
 		auth.POST("/register", h.registerUser)
 		auth.POST("/login", h.loginUser)
+		auth.POST("/refresh", h.refreshSession)
 		auth.GET("/me", h.authMiddleware(), h.currentUser)
+		auth.POST("/logout", h.authMiddleware(), h.logout)
+		auth.POST("/logout-all", h.authMiddleware(), h.logoutAll)
+		auth.GET("/api-keys", h.authMiddleware(), h.requireSession(), h.listAPIKeys)
+		auth.POST("/api-keys", h.authMiddleware(), h.requireSession(), h.createAPIKey)
+		auth.DELETE("/api-keys/:id", h.authMiddleware(), h.requireSession(), h.revokeAPIKey)
 	}
 
 	protected := api.Group("")
 	protected.Use(h.authMiddleware())
 	{
-		protected.POST("/tasks", h.createTask)
-		protected.GET("/tasks", h.listTasks)
-		protected.GET("/tasks/:id", h.getTask)
-		protected.DELETE("/tasks/:id", h.deleteTask)
-		protected.GET("/storage/objects", h.listObjects)
+		protected.POST("/tasks", h.requireScope(domain.ScopeTasksWrite), h.createTask)
+		protected.GET("/tasks", h.requireScope(domain.ScopeTasksRead), h.listTasks)
+		protected.POST("/tasks/batch", h.requireScope(domain.ScopeTasksWrite), h.createTaskBatch)
+		protected.POST("/tasks/bulk", h.requireScope(domain.ScopeTasksWrite), h.bulkTaskAction)
+		protected.GET("/tasks/events", h.requireScope(domain.ScopeTasksRead), h.taskEvents)
+		protected.GET("/tasks/queue", h.requireScope(domain.ScopeTasksRead), h.listQueue)
+		protected.GET("/tasks/:id", h.requireScope(domain.ScopeTasksRead), h.getTask)
+		protected.DELETE("/tasks/:id", h.requireScope(domain.ScopeTasksWrite), h.deleteTask)
+		protected.POST("/tasks/:id/pause", h.requireScope(domain.ScopeTasksWrite), h.pauseTask)
+		protected.POST("/tasks/:id/resume", h.requireScope(domain.ScopeTasksWrite), h.resumeTask)
+		protected.POST("/tasks/:id/retry", h.requireScope(domain.ScopeTasksWrite), h.retryTask)
+		protected.PATCH("/tasks/:id/files", h.requireScope(domain.ScopeTasksWrite), h.updateTaskFiles)
+		protected.PUT("/tasks/:id/bandwidth", h.requireScope(domain.ScopeTasksWrite), h.updateTaskBandwidth)
+		protected.PUT("/tasks/:id/seeding", h.requireScope(domain.ScopeTasksWrite), h.updateTaskSeeding)
+		protected.PUT("/tasks/:id/queue", h.requireScope(domain.ScopeTasksWrite), h.updateTaskQueue)
+		protected.POST("/tasks/:id/queue/:move", h.requireScope(domain.ScopeTasksWrite), h.moveTaskInQueue)
+		protected.GET("/tasks/:id/files/:fileId/stream", h.requireScope(domain.ScopeTasksRead), h.streamTaskFile)
+		protected.GET("/tasks/:id/files/:fileId/stream-url", h.requireScope(domain.ScopeTasksRead), h.taskFileStreamURL)
+		protected.GET("/tasks/:id/files/:fileId/url", h.requireScope(domain.ScopeStorageRead), h.taskFileURL)
+		protected.GET("/storage/objects", h.requireScope(domain.ScopeStorageRead), h.listObjects)
+		protected.GET("/storage/objects/url", h.requireScope(domain.ScopeStorageRead), h.objectURL)
 	}
 
+	admin := api.Group("/admin")
+	admin.Use(h.authMiddleware(), h.requireSession(), h.requireRole(domain.UserRoleAdmin))
+	{
+		admin.GET("/users", h.listUsers)
+		admin.PATCH("/users/:id", h.updateUser)
+		admin.POST("/users/:id/password", h.resetUserPassword)
+		admin.DELETE("/users/:id", h.deleteUser)
+	}
+
+	settings := api.Group("/settings")
+	settings.Use(h.authMiddleware(), h.requireSession(), h.requireRole(domain.UserRoleAdmin))
+	{
+		settings.GET("/bandwidth", h.getBandwidth)
+		settings.PUT("/bandwidth", h.updateBandwidth)
+		settings.GET("/queue", h.getQueueSettings)
+		settings.PUT("/queue", h.updateQueueSettings)
+	}
+
+	// filesystem storage serves presigned links itself; the signature authorizes the request
+	api.GET("/storage/files/*key", h.serveStorageFile)
+
 	api.GET("/health", func(ctx *gin.Context) {
 		ctx.JSON(http.StatusAccepted, gin.H{"ok": "ok"})
 	})
@@ -82,6 +135,13 @@ type createTaskRequest struct {
 	Magnet string `json:"magnet" binding:"required"`
 }
 
+type updateTaskFilesRequest struct {
+	Files []struct {
+		ID       int64  `json:"id" binding:"required"`
+		Priority string `json:"priority" binding:"required"`
+	} `json:"files" binding:"required"`
+}
+
 type registerRequest struct {
 	Username       string `json:"username" binding:"required"`
 	Password       string `json:"password" binding:"required"`
@@ -93,19 +153,29 @@ type loginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
 type authResponse struct {
-	Token string       `json:"token"`
-	User  UserResponse `json:"user"`
+	Token        string       `json:"token"`
+	ExpiresAt    string       `json:"expires_at"`
+	RefreshToken string       `json:"refresh_token"`
+	User         UserResponse `json:"user"`
 }
 
-const contextUserKey = "authUser"
+const (
+	contextUserKey    = "authUser"
+	contextSessionKey = "authSession"
+	contextAPIKeyKey  = "authAPIKey"
+)
 
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Disposition")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-API-Key, Range, Last-Event-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Disposition, Content-Range, Accept-Ranges, Content-Length, X-Total-Count, X-Next-Cursor")
 		if c.Request.Method == http.MethodOptions {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
@@ -145,16 +215,13 @@ func (h *Handler) registerUser(c *gin.Context) {
 		return
 	}
 
-	token, err := h.generateToken(user)
+	resp, err := h.startSession(c.Request.Context(), user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, authResponse{
-		Token: token,
-		User:  userToResponse(*user),
-	})
+	c.JSON(http.StatusCreated, resp)
 }
 
 func (h *Handler) loginUser(c *gin.Context) {
@@ -175,20 +242,21 @@ func (h *Handler) loginUser(c *gin.Context) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
 			return
 		}
+		if errors.Is(err, service.ErrUserDisabled) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "account disabled"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "authentication failed"})
 		return
 	}
 
-	token, err := h.generateToken(user)
+	resp, err := h.startSession(c.Request.Context(), user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, authResponse{
-		Token: token,
-		User:  userToResponse(*user),
-	})
+	c.JSON(http.StatusOK, resp)
 }
 
 func (h *Handler) currentUser(c *gin.Context) {
@@ -207,7 +275,16 @@ func (h *Handler) authMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		if apiKey := strings.TrimSpace(c.GetHeader("X-API-Key")); apiKey != "" {
+			h.authenticateAPIKey(c, apiKey)
+			return
+		}
+
 		authHeader := strings.TrimSpace(c.GetHeader("Authorization"))
+		if authHeader == "" && c.FullPath() == streamRoute && c.Query("signature") != "" {
+			h.authenticateStreamURL(c)
+			return
+		}
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization header missing"})
 			return
@@ -224,6 +301,10 @@ func (h *Handler) authMiddleware() gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token missing"})
 			return
 		}
+		if strings.HasPrefix(tokenString, domain.APIKeyPrefix) {
+			h.authenticateAPIKey(c, tokenString)
+			return
+		}
 
 		claims := &tokenClaims{}
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
@@ -250,313 +331,1237 @@ func (h *Handler) authMiddleware() gin.HandlerFunc {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token user"})
 			return
 		}
+		if user.Disabled {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "account disabled"})
+			return
+		}
+
+		if h.sessions != nil {
+			if err := h.sessions.Validate(c.Request.Context(), claims.SessionID, user.ID); err != nil {
+				if errors.Is(err, service.ErrSessionRevoked) {
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session revoked"})
+					return
+				}
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "session validation failed"})
+				return
+			}
+		}
 
 		c.Set(contextUserKey, user)
+		c.Set(contextSessionKey, claims.SessionID)
 		c.Next()
 	}
 }
 
-func (h *Handler) generateToken(user *domain.User) (string, error) {
-	if user == nil {
-		return "", fmt.Errorf("user is required")
-	}
-	if len(h.jwtSecret) == 0 {
-		return "", fmt.Errorf("jwt secret not configured")
+// authenticateAPIKey authorizes the request with a personal API key and continues the chain.
+func (h *Handler) authenticateAPIKey(c *gin.Context, rawKey string) {
+	if h.users == nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user service not configured"})
+		return
 	}
 
-	now := time.Now()
-	claims := tokenClaims{
-		UserID:   user.ID,
-		Username: user.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(h.tokenTTL)),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(h.jwtSecret)
+	user, key, err := h.users.AuthenticateAPIKey(c.Request.Context(), rawKey)
 	if err != nil {
-		return "", fmt.Errorf("sign token: %w", err)
+		switch {
+		case errors.Is(err, service.ErrInvalidAPIKey):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+		case errors.Is(err, service.ErrUserDisabled):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "account disabled"})
+		default:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "api key validation failed"})
+		}
+		return
 	}
-	return signed, nil
-}
 
-type tokenClaims struct {
-	UserID   int64  `json:"user_id"`
-	Username string `json:"username"`
-	jwt.RegisteredClaims
+	c.Set(contextUserKey, user)
+	c.Set(contextAPIKeyKey, key)
+	c.Next()
 }
 
-func (h *Handler) createTask(c *gin.Context) {
-	var req createTaskRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// requireScope rejects API key requests whose key does not grant scope.
+// Session tokens carry every scope. It must run after authMiddleware.
+func (h *Handler) requireScope(scope domain.APIKeyScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key, ok := apiKeyFromContext(c); ok && !key.Allows(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("api key lacks scope %s", scope)})
+			return
+		}
+		c.Next()
 	}
+}
 
-	task, err := h.tasks.CreateTask(c.Request.Context(), req.Magnet, h.dataRoot)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// requireSession rejects requests authorized with an API key, so a leaked key
+// cannot mint new keys or reach administrative endpoints.
+func (h *Handler) requireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := apiKeyFromContext(c); ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api keys cannot access this endpoint"})
+			return
+		}
+		c.Next()
 	}
+}
 
-	if err := h.manager.Enqueue(c.Request.Context(), task.ID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+// requireRole rejects requests from users that do not hold role. It must run after authMiddleware.
+func (h *Handler) requireRole(role domain.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		user, ok := userFromContext(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user context missing"})
+			return
+		}
+		if user.Role != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
+		c.Next()
 	}
-
-	c.JSON(http.StatusAccepted, taskToResponse(*task))
 }
 
-func (h *Handler) listTasks(c *gin.Context) {
-	tasks, err := h.tasks.ListTasks(c.Request.Context())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func (h *Handler) refreshSession(c *gin.Context) {
+	if h.users == nil || h.sessions == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user service not configured"})
 		return
 	}
 
-	resp := make([]TaskResponse, len(tasks))
-	for i := range tasks {
-		resp[i] = taskToResponse(tasks[i])
-	}
-	c.JSON(http.StatusOK, resp)
-}
-
-func (h *Handler) getTask(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil || id <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	task, err := h.tasks.GetTask(c.Request.Context(), id)
+	session, refreshToken, err := h.sessions.Refresh(c.Request.Context(), req.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		if errors.Is(err, service.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "refresh failed"})
 		return
 	}
 
-	c.JSON(http.StatusOK, taskToResponse(*task))
-}
-
-func (h *Handler) deleteTask(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil || id <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+	user, err := h.users.GetByID(c.Request.Context(), session.UserID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token user"})
 		return
 	}
-
-	deleteRemote, err := strconv.ParseBool(c.DefaultQuery("delete_remote", "false"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid flag delete_remote"})
+	if user.Disabled {
+		_ = h.sessions.Revoke(c.Request.Context(), session.ID)
+		c.JSON(http.StatusForbidden, gin.H{"error": "account disabled"})
 		return
 	}
 
-	task, err := h.tasks.GetTask(c.Request.Context(), id)
+	token, expiresAt, err := h.generateToken(user, session.ID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	var warnings []string
-	if h.manager != nil {
-		cancelCtx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
-		defer cancel()
-		if err := h.manager.Cancel(cancelCtx, task.ID); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
-			warnings = append(warnings, fmt.Sprintf("cancel task: %v", err))
-		}
-	}
+	c.JSON(http.StatusOK, authResponse{
+		Token:        token,
+		ExpiresAt:    expiresAt.Format(time.RFC3339),
+		RefreshToken: refreshToken,
+		User:         userToResponse(*user),
+	})
+}
 
-	if deleteRemote {
-		if h.storage == nil || h.bucket == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "storage service not configured"})
+func (h *Handler) logout(c *gin.Context) {
+	sessionID := c.GetString(contextSessionKey)
+	if h.sessions != nil && sessionID != "" {
+		if err := h.sessions.Revoke(c.Request.Context(), sessionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		if task.S3Location != "" {
-			prefix, err := extractS3Prefix(task.S3Location, h.bucket)
-			if err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-				return
-			}
-			if prefix != "" {
-				remoteCtx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
-				defer cancel()
-				if err := h.storage.DeletePrefix(remoteCtx, h.bucket, prefix); err != nil {
-					warnings = append(warnings, fmt.Sprintf("delete remote data: %v", err))
-				}
-			}
-		}
 	}
+	c.Status(http.StatusNoContent)
+}
 
-	warnings = append(warnings, h.cleanupLocalData(task)...)
-
-	if err := h.tasks.DeleteTask(c.Request.Context(), task.ID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func (h *Handler) logoutAll(c *gin.Context) {
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
 		return
 	}
-
-	resp := gin.H{"deleted": task.ID}
-	if len(warnings) > 0 {
-		resp["warnings"] = warnings
+	if h.sessions != nil {
+		if err := h.sessions.RevokeAll(c.Request.Context(), user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 	}
-	c.JSON(http.StatusOK, resp)
+	c.Status(http.StatusNoContent)
 }
 
-func (h *Handler) listObjects(c *gin.Context) {
-	if h.storage == nil || h.bucket == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "storage service not configured"})
-		return
+// startSession opens a new session for user and returns its access and refresh tokens.
+func (h *Handler) startSession(ctx context.Context, user *domain.User) (authResponse, error) {
+	if h.sessions == nil {
+		return authResponse{}, fmt.Errorf("session service not configured")
 	}
-
-	prefix := c.Query("prefix")
-	objects, err := h.storage.ListObjects(c.Request.Context(), h.bucket, prefix)
+	session, refreshToken, err := h.sessions.Create(ctx, user.ID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return authResponse{}, err
 	}
-
-	resp := make([]StorageObjectResponse, len(objects))
-	for i := range objects {
-		resp[i] = objectToResponse(objects[i])
+	token, expiresAt, err := h.generateToken(user, session.ID)
+	if err != nil {
+		return authResponse{}, err
 	}
-	c.JSON(http.StatusOK, resp)
+	return authResponse{
+		Token:        token,
+		ExpiresAt:    expiresAt.Format(time.RFC3339),
+		RefreshToken: refreshToken,
+		User:         userToResponse(*user),
+	}, nil
 }
 
-func userFromContext(c *gin.Context) (*domain.User, bool) {
-	value, ok := c.Get(contextUserKey)
-	if !ok {
-		return nil, false
+func (h *Handler) generateToken(user *domain.User, sessionID string) (string, time.Time, error) {
+	if user == nil {
+		return "", time.Time{}, fmt.Errorf("user is required")
 	}
-	user, ok := value.(*domain.User)
-	if !ok || user == nil {
-		return nil, false
+	if len(h.jwtSecret) == 0 {
+		return "", time.Time{}, fmt.Errorf("jwt secret not configured")
 	}
-	return user, true
-}
 
-type UserResponse struct {
-	ID       int64  `json:"id"`
-	Username string `json:"username"`
+	now := time.Now()
+	expiresAt := now.Add(h.tokenTTL)
+	claims := tokenClaims{
+		UserID:    user.ID,
+		Username:  user.Username,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString(h.jwtSecret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("sign token: %w", err)
+	}
+	return signed, expiresAt, nil
 }
 
-func userToResponse(user domain.User) UserResponse {
-	return UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-	}
+type tokenClaims struct {
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
 }
 
-type TaskResponse struct {
-	ID               int64              `json:"id"`
-	Magnet           string             `json:"magnet"`
-	Status           domain.TaskStatus  `json:"status"`
-	Progress         int                `json:"progress"`
-	Speed            int64              `json:"speed"`
-	DownloadedBytes  int64              `json:"downloaded_bytes"`
-	TotalSize        int64              `json:"total_size"`
-	TotalPeers       int                `json:"total_peers"`
-	ActivePeers      int                `json:"active_peers"`
-	PendingPeers     int                `json:"pending_peers"`
-	ConnectedSeeders int                `json:"connected_seeders"`
-	HalfOpenPeers    int                `json:"half_open_peers"`
-	TorrentName      string             `json:"torrent_name"`
-	LocalPath        string             `json:"local_path"`
-	S3Location       string             `json:"s3_location"`
-	ErrorMessage     string             `json:"error_message"`
-	CreatedAt        string             `json:"created_at"`
-	UpdatedAt        string             `json:"updated_at"`
-	DownloadedAt     *string            `json:"downloaded_at,omitempty"`
-	UploadedAt       *string            `json:"uploaded_at,omitempty"`
-	Files            []TaskFileResponse `json:"files"`
-}
-
-func (h *Handler) cleanupLocalData(task *domain.Task) []string {
-	root := filepath.Clean(h.dataRoot)
-	seen := make(map[string]struct{})
-	var warnings []string
+func (h *Handler) createTask(c *gin.Context) {
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
+		return
+	}
 
-	addPath := func(p string, restrictToRoot bool) {
-		if p == "" {
-			return
-		}
-		clean := filepath.Clean(p)
-		if clean == "" || clean == "." {
+	var (
+		task *domain.Task
+		err  error
+	)
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		data, readErr := readTorrentUpload(c)
+		if readErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": readErr.Error()})
 			return
 		}
-		if restrictToRoot {
-			if root == "" {
+		task, err = h.tasks.CreateTaskFromTorrent(c.Request.Context(), user.ID, data, h.dataRoot)
+		if err != nil {
+			if h.respondDuplicateTask(c, user, err) {
 				return
 			}
-			if rel, err := filepath.Rel(root, clean); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			if strings.Contains(err.Error(), "invalid torrent file") {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
-		} else if root != "" && clean == root {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
-		if _, ok := seen[clean]; ok {
+	} else {
+		var req createTaskRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		seen[clean] = struct{}{}
-		if err := os.RemoveAll(clean); err != nil && !os.IsNotExist(err) {
-			warnings = append(warnings, fmt.Sprintf("remove local data %s: %v", clean, err))
+
+		task, err = h.tasks.CreateTask(c.Request.Context(), user.ID, req.Magnet, h.dataRoot)
+		if err != nil {
+			if h.respondDuplicateTask(c, user, err) {
+				return
+			}
+			var magnetErr *domain.MagnetError
+			if errors.As(err, &magnetErr) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "fields": magnetErr.Fields})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
 	}
 
-	addPath(task.LocalPath, false)
-	if infoHash, err := infoHashFromMagnet(task.MagnetURI); err == nil {
-		addPath(filepath.Join(root, infoHash), true)
+	if err := h.startTask(c.Request.Context(), task); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	return warnings
+	c.JSON(http.StatusAccepted, taskToResponse(*task))
 }
 
-func infoHashFromMagnet(uri string) (string, error) {
-	parsed, err := url.Parse(uri)
-	if err != nil {
-		return "", err
+// startTask hands a newly created task to the downloader and announces it.
+func (h *Handler) startTask(ctx context.Context, task *domain.Task) error {
+	if err := h.manager.Enqueue(ctx, task.ID); err != nil {
+		return err
 	}
-	if parsed.Scheme != "magnet" {
-		return "", fmt.Errorf("invalid magnet URI scheme")
+
+	h.events.Publish(events.TaskEvent{
+		Type:   events.TypeCreated,
+		TaskID: task.ID,
+		UserID: task.UserID,
+		Status: task.Status,
+	})
+	return nil
+}
+
+// respondDuplicateTask answers 409 when err reports that the user already has
+// a task for the same torrent, after handing any new trackers to it.
+func (h *Handler) respondDuplicateTask(c *gin.Context, user *domain.User, err error) bool {
+	var dup *service.DuplicateTaskError
+	if !errors.As(err, &dup) {
+		return false
 	}
-	values, err := url.ParseQuery(parsed.RawQuery)
-	if err != nil {
-		return "", err
+	if dup.Task.UserID != user.ID {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "duplicate check returned a foreign task"})
+		return true
 	}
+	// best effort: the trackers are stored and apply on the next start anyway
+	_ = h.manager.ApplyTrackers(c.Request.Context(), dup.Task.ID)
+	c.JSON(http.StatusConflict, gin.H{
+		"error":   "task already exists",
+		"task_id": dup.Task.ID,
+		"task":    taskToResponse(*dup.Task),
+	})
+	return true
+}
 
-	for _, xt := range values["xt"] {
-		if !strings.HasPrefix(strings.ToLower(xt), "urn:btih:") {
-			continue
-		}
-		hash := strings.TrimSpace(xt[len("urn:btih:"):])
-		if len(hash) == 0 {
-			continue
-		}
-		if len(hash) == 40 {
-			if _, err := hex.DecodeString(hash); err == nil {
-				return strings.ToLower(hash), nil
-			}
-		}
+const maxTorrentFileSize = 10 << 20
 
-		encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
-		base32Value := strings.TrimRight(strings.ToUpper(hash), "=")
-		decoded, err := encoding.DecodeString(base32Value)
-		if err != nil || len(decoded) != 20 {
-			continue
-		}
-		return hex.EncodeToString(decoded), nil
+func readTorrentUpload(c *gin.Context) ([]byte, error) {
+	header, err := c.FormFile("torrent")
+	if err != nil {
+		return nil, fmt.Errorf("torrent file is required")
+	}
+	if header.Size > maxTorrentFileSize {
+		return nil, fmt.Errorf("torrent file exceeds %d bytes", maxTorrentFileSize)
 	}
 
-	return "", fmt.Errorf("btih magnet xt not present")
-}
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open torrent file: %w", err)
+	}
+	defer file.Close()
 
-type TaskFileResponse struct {
-	ID       int64  `json:"id"`
-	TaskID   int64  `json:"task_id"`
-	Name     string `json:"name"`
-	Path     string `json:"path"`
-	Size     int64  `json:"size"`
-	Priority int    `json:"priority"`
+	data, err := io.ReadAll(io.LimitReader(file, maxTorrentFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read torrent file: %w", err)
+	}
+	if len(data) > maxTorrentFileSize {
+		return nil, fmt.Errorf("torrent file exceeds %d bytes", maxTorrentFileSize)
+	}
+	return data, nil
+}
+
+func (h *Handler) listTasks(c *gin.Context) {
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
+		return
+	}
+
+	opts, err := parseTaskListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// admins may list the tasks of another user, or of everyone with owner=all
+	opts.UserID = user.ID
+	if owner := strings.TrimSpace(c.Query("owner")); owner != "" {
+		ownerID, err := strconv.ParseInt(owner, 10, 64)
+		switch {
+		case owner == "all":
+			ownerID = 0
+		case err != nil || ownerID <= 0:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid owner"})
+			return
+		}
+		if ownerID != user.ID && !user.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "admin role required to list other users' tasks"})
+			return
+		}
+		opts.UserID = ownerID
+	}
+
+	page, err := h.tasks.ListTaskPage(c.Request.Context(), opts)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(page.Total))
+	if page.NextCursor != "" {
+		c.Header("X-Next-Cursor", page.NextCursor)
+	}
+	resp := make([]TaskResponse, len(page.Tasks))
+	for i := range page.Tasks {
+		resp[i] = taskToResponse(page.Tasks[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// maxTaskPageSize caps the limit of a task list request.
+const maxTaskPageSize = 500
+
+// parseTaskListQuery reads the filter, sort and paging parameters of a task
+// list request. Without a limit every matching task is returned.
+func parseTaskListQuery(c *gin.Context) (domain.TaskListOptions, error) {
+	var opts domain.TaskListOptions
+
+	for _, value := range c.QueryArray("status") {
+		for _, name := range strings.Split(value, ",") {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			status, err := domain.ParseTaskStatus(name)
+			if err != nil {
+				return opts, err
+			}
+			opts.Statuses = append(opts.Statuses, status)
+		}
+	}
+	opts.Name = strings.TrimSpace(c.Query("name"))
+
+	var err error
+	if opts.CreatedFrom, err = parseTaskListTime(c.Query("created_from"), false); err != nil {
+		return opts, fmt.Errorf("invalid created_from: %w", err)
+	}
+	if opts.CreatedTo, err = parseTaskListTime(c.Query("created_to"), true); err != nil {
+		return opts, fmt.Errorf("invalid created_to: %w", err)
+	}
+
+	if opts.Sort, err = domain.ParseTaskSort(c.Query("sort")); err != nil {
+		return opts, err
+	}
+	switch strings.ToLower(c.DefaultQuery("order", "desc")) {
+	case "asc":
+		opts.Ascending = true
+	case "desc":
+	default:
+		return opts, fmt.Errorf("order must be asc or desc")
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxTaskPageSize {
+			return opts, fmt.Errorf("limit must be between 1 and %d", maxTaskPageSize)
+		}
+		opts.Limit = limit
+	}
+	opts.Cursor = strings.TrimSpace(c.Query("cursor"))
+
+	files, err := strconv.ParseBool(c.DefaultQuery("files", "true"))
+	if err != nil {
+		return opts, fmt.Errorf("invalid flag files")
+	}
+	opts.OmitFiles = !files
+	return opts, nil
+}
+
+// parseTaskListTime accepts RFC 3339 times and plain dates. A plain date used
+// as an upper bound covers the whole day.
+func parseTaskListTime(raw string, endOfDay bool) (*time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, raw, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("expected RFC 3339 time or YYYY-MM-DD date")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+func (h *Handler) taskEvents(c *gin.Context) {
+	if h.events == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "event bus not configured"})
+		return
+	}
+
+	var lastID uint64
+	if raw := strings.TrimSpace(c.GetHeader("Last-Event-ID")); raw != "" {
+		lastID, _ = strconv.ParseUint(raw, 10, 64)
+	} else if raw := strings.TrimSpace(c.Query("last_event_id")); raw != "" {
+		lastID, _ = strconv.ParseUint(raw, 10, 64)
+	}
+
+	sub, backlog := h.events.Subscribe(lastID, h.taskEventFilter(c))
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	for _, evt := range backlog {
+		if err := writeTaskEvent(c.Writer, evt); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case evt, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if err := writeTaskEvent(c.Writer, evt); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// taskEventFilter limits the event stream to tasks the requesting user can see.
+func (h *Handler) taskEventFilter(c *gin.Context) func(events.TaskEvent) bool {
+	user, ok := userFromContext(c)
+	if !ok {
+		return func(events.TaskEvent) bool { return false }
+	}
+	return func(evt events.TaskEvent) bool {
+		return evt.Type == events.TypeResync || evt.UserID == user.ID
+	}
+}
+
+func writeTaskEvent(w io.Writer, evt events.TaskEvent) error {
+	payload, err := json.Marshal(taskEventToResponse(evt))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", evt.ID, evt.Type, payload)
+	return err
+}
+
+func (h *Handler) getTask(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+
+	task, err := h.userTask(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, taskToResponse(*task))
+}
+
+func (h *Handler) deleteTask(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+
+	deleteRemote, err := strconv.ParseBool(c.DefaultQuery("delete_remote", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid flag delete_remote"})
+		return
+	}
+
+	task, err := h.userTask(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	warnings, err := h.discardTaskData(c.Request.Context(), task, deleteRemote)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.tasks.DeleteTask(c.Request.Context(), task.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.events.Publish(events.TaskEvent{
+		Type:   events.TypeDeleted,
+		TaskID: task.ID,
+		UserID: task.UserID,
+	})
+
+	resp := gin.H{"deleted": task.ID}
+	if len(warnings) > 0 {
+		resp["warnings"] = warnings
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// discardTaskData stops a task that is about to be deleted and removes its
+// local data, and its remote data when deleteRemote is set. Cleanup problems
+// are returned as warnings; an error means the request itself is invalid.
+func (h *Handler) discardTaskData(ctx context.Context, task *domain.Task, deleteRemote bool) ([]string, error) {
+	var warnings []string
+	if h.manager != nil {
+		cancelCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := h.manager.Cancel(cancelCtx, task.ID); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			warnings = append(warnings, fmt.Sprintf("cancel task: %v", err))
+		}
+	}
+
+	if deleteRemote {
+		if h.storage == nil {
+			return nil, fmt.Errorf("storage service not configured")
+		}
+		if task.S3Location != "" {
+			prefix, err := extractStoragePrefix(task.S3Location, h.bucket)
+			if err != nil {
+				return nil, err
+			}
+			if prefix != "" {
+				remoteCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+				defer cancel()
+				if err := h.storage.DeletePrefix(remoteCtx, h.bucket, prefix); err != nil {
+					warnings = append(warnings, fmt.Sprintf("delete remote data: %v", err))
+				}
+			}
+		}
+	}
+
+	return append(warnings, h.cleanupLocalData(ctx, task)...), nil
+}
+
+func (h *Handler) pauseTask(c *gin.Context) {
+	h.changeTaskState(c, h.manager.Pause)
+}
+
+func (h *Handler) resumeTask(c *gin.Context) {
+	h.changeTaskState(c, h.manager.ResumeTask)
+}
+
+func (h *Handler) retryTask(c *gin.Context) {
+	h.changeTaskState(c, h.manager.Retry)
+}
+
+func (h *Handler) changeTaskState(c *gin.Context, action func(ctx context.Context, taskID int64) error) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+
+	if _, err := h.userTask(c, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	actionCtx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	if err := action(actionCtx, id); err != nil {
+		if errors.Is(err, downloader.ErrInvalidTaskState) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.tasks.GetTask(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, taskToResponse(*task))
+}
+
+func (h *Handler) updateTaskFiles(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+
+	var req updateTaskFilesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	priorities := make(map[int64]int, len(req.Files))
+	for _, file := range req.Files {
+		priority, err := domain.ParseFilePriority(file.Priority)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		priorities[file.ID] = priority
+	}
+
+	task, err := h.userTask(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	switch task.Status {
+	case domain.TaskStatusDownloaded, domain.TaskStatusUploading, domain.TaskStatusSeeding, domain.TaskStatusCompleted:
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("cannot change files of %s task", task.Status)})
+		return
+	}
+
+	if err := h.tasks.SetFilePriorities(c.Request.Context(), task.ID, priorities); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.manager.ApplyFilePriorities(c.Request.Context(), task.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err = h.tasks.GetTask(c.Request.Context(), task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, taskToResponse(*task))
+}
+
+func (h *Handler) streamTaskFile(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+	fileID, err := strconv.ParseInt(c.Param("fileId"), 10, 64)
+	if err != nil || fileID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+		return
+	}
+
+	task, err := h.userTask(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	file, ok := findTaskFile(task, fileID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task file not found"})
+		return
+	}
+
+	stream, err := h.manager.OpenStream(c.Request.Context(), task.ID, file.Path)
+	if err != nil {
+		if errors.Is(err, downloader.ErrStreamUnavailable) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer stream.Close()
+
+	if contentType := mime.TypeByExtension(filepath.Ext(stream.Name)); contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", filepath.Base(stream.Name)))
+	http.ServeContent(c.Writer, c.Request, stream.Name, time.Time{}, stream)
+}
+
+func findTaskFile(task *domain.Task, fileID int64) (*domain.TaskFile, bool) {
+	for i := range task.Files {
+		if task.Files[i].ID == fileID {
+			return &task.Files[i], true
+		}
+	}
+	return nil, false
+}
+
+func (h *Handler) listObjects(c *gin.Context) {
+	if h.storage == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "storage service not configured"})
+		return
+	}
+
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
+		return
+	}
+
+	roots := h.objectRoots(user)
+	prefix := strings.TrimPrefix(strings.TrimSpace(c.Query("prefix")), "/")
+	var prefixes []string
+	for _, root := range roots {
+		if strings.HasPrefix(prefix, root) {
+			prefixes = []string{prefix}
+			break
+		}
+	}
+	if prefixes == nil {
+		for _, root := range roots {
+			prefixes = append(prefixes, root+prefix)
+		}
+	}
+	var objects []storage.ObjectInfo
+	for _, p := range prefixes {
+		listed, err := h.storage.ListObjects(c.Request.Context(), h.bucket, p)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		objects = append(objects, listed...)
+	}
+
+	resp := make([]StorageObjectResponse, len(objects))
+	for i := range objects {
+		resp[i] = objectToResponse(objects[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+const (
+	defaultPresignTTL = 15 * time.Minute
+	maxPresignTTL     = 24 * time.Hour
+)
+
+type PresignedURLResponse struct {
+	Key       string `json:"key"`
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+func (h *Handler) objectURL(c *gin.Context) {
+	key := strings.TrimPrefix(strings.TrimSpace(c.Query("key")), "/")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "object key is required"})
+		return
+	}
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
+		return
+	}
+	if !h.ownsObject(user, key) || strings.Contains(key, "..") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "object not found"})
+		return
+	}
+	h.presignObject(c, key)
+}
+
+func (h *Handler) taskFileURL(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+	fileID, err := strconv.ParseInt(c.Param("fileId"), 10, 64)
+	if err != nil || fileID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+		return
+	}
+
+	task, err := h.userTask(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	file, ok := findTaskFile(task, fileID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task file not found"})
+		return
+	}
+	// seeding tasks have finished uploading too, so the stored location decides
+	if task.S3Location == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "task has not been uploaded yet"})
+		return
+	}
+	if !file.Selected() {
+		c.JSON(http.StatusConflict, gin.H{"error": "task file was skipped"})
+		return
+	}
+
+	prefix, err := extractStoragePrefix(task.S3Location, h.bucket)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.presignObject(c, path.Join(prefix, file.Name))
+}
+
+// parsePresignTTL reads a signed URL lifetime in seconds, defaulting to
+// defaultPresignTTL and capped at maxPresignTTL.
+func parsePresignTTL(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultPresignTTL, nil
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return 0, fmt.Errorf("invalid ttl")
+	}
+	return min(time.Duration(seconds)*time.Second, maxPresignTTL), nil
+}
+
+// presignObject responds with a signed GET URL for key. The optional ttl
+// (seconds) and disposition (inline or attachment) query parameters tune it.
+func (h *Handler) presignObject(c *gin.Context, key string) {
+	if h.storage == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "storage service not configured"})
+		return
+	}
+
+	ttl, err := parsePresignTTL(c.Query("ttl"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var opts storage.PresignOptions
+	switch disposition := c.Query("disposition"); disposition {
+	case "":
+	case "inline", "attachment":
+		opts.ContentDisposition = mime.FormatMediaType(disposition, map[string]string{"filename": path.Base(key)})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "disposition must be inline or attachment"})
+		return
+	}
+
+	signed, err := h.storage.PresignGet(c.Request.Context(), h.bucket, key, ttl, opts)
+	if err != nil {
+		if errors.Is(err, storage.ErrPresignUnsupported) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, PresignedURLResponse{
+		Key:       key,
+		URL:       signed,
+		ExpiresAt: time.Now().Add(ttl).Format(time.RFC3339),
+	})
+}
+
+// serveStorageFile delivers an object of a storage backend that keeps data on
+// local disk, using the presigned query produced by its PresignGet.
+func (h *Handler) serveStorageFile(c *gin.Context) {
+	local, ok := h.storage.(storage.LocalObjectServer)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "object not found"})
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	opts, err := local.VerifyPresigned(key, c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	f, err := local.OpenObject(key)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "object not found"})
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if opts.ContentDisposition != "" {
+		c.Header("Content-Disposition", opts.ContentDisposition)
+	}
+	http.ServeContent(c.Writer, c.Request, info.Name(), info.ModTime(), f)
+}
+
+// userObjectRoot is the key prefix, with trailing slash, that holds the user's uploads.
+func (h *Handler) userObjectRoot(user *domain.User) string {
+	return storage.UserKeyPrefix(h.keyPrefix, user.ID) + "/"
+}
+
+// objectRoots lists the key prefixes a user may browse. Admins also see the
+// task-<id> uploads made before uploads were namespaced per user.
+func (h *Handler) objectRoots(user *domain.User) []string {
+	roots := []string{h.userObjectRoot(user)}
+	if user.IsAdmin() {
+		legacy := "task-"
+		if base := strings.Trim(h.keyPrefix, "/"); base != "" {
+			legacy = base + "/task-"
+		}
+		roots = append(roots, legacy)
+	}
+	return roots
+}
+
+func (h *Handler) ownsObject(user *domain.User, key string) bool {
+	for _, root := range h.objectRoots(user) {
+		if strings.HasPrefix(key, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// userTask loads a task owned by the authenticated user.
+func (h *Handler) userTask(c *gin.Context, id int64) (*domain.Task, error) {
+	user, ok := userFromContext(c)
+	if !ok {
+		return nil, service.ErrTaskNotFound
+	}
+	return h.tasks.GetUserTask(c.Request.Context(), user.ID, id)
+}
+
+func userFromContext(c *gin.Context) (*domain.User, bool) {
+	value, ok := c.Get(contextUserKey)
+	if !ok {
+		return nil, false
+	}
+	user, ok := value.(*domain.User)
+	if !ok || user == nil {
+		return nil, false
+	}
+	return user, true
+}
+
+func apiKeyFromContext(c *gin.Context) (*domain.APIKey, bool) {
+	value, ok := c.Get(contextAPIKeyKey)
+	if !ok {
+		return nil, false
+	}
+	key, ok := value.(*domain.APIKey)
+	if !ok || key == nil {
+		return nil, false
+	}
+	return key, true
+}
+
+type UserResponse struct {
+	ID        int64           `json:"id"`
+	Username  string          `json:"username"`
+	Role      domain.UserRole `json:"role"`
+	Disabled  bool            `json:"disabled"`
+	CreatedAt string          `json:"created_at"`
+}
+
+func userToResponse(user domain.User) UserResponse {
+	return UserResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		Disabled:  user.Disabled,
+		CreatedAt: user.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+type TaskResponse struct {
+	ID               int64               `json:"id"`
+	Magnet           string              `json:"magnet"`
+	InfoHash         string              `json:"info_hash"`
+	Status           domain.TaskStatus   `json:"status"`
+	Progress         int                 `json:"progress"`
+	Speed            int64               `json:"speed"`
+	DownloadedBytes  int64               `json:"downloaded_bytes"`
+	TotalSize        int64               `json:"total_size"`
+	TotalPeers       int                 `json:"total_peers"`
+	ActivePeers      int                 `json:"active_peers"`
+	PendingPeers     int                 `json:"pending_peers"`
+	ConnectedSeeders int                 `json:"connected_seeders"`
+	HalfOpenPeers    int                 `json:"half_open_peers"`
+	TorrentName      string              `json:"torrent_name"`
+	LocalPath        string              `json:"local_path"`
+	S3Location       string              `json:"s3_location"`
+	ErrorMessage     string              `json:"error_message"`
+	CreatedAt        string              `json:"created_at"`
+	UpdatedAt        string              `json:"updated_at"`
+	DownloadedAt     *string             `json:"downloaded_at,omitempty"`
+	UploadedAt       *string             `json:"uploaded_at,omitempty"`
+	DownloadLimit    int64               `json:"download_limit"`
+	UploadLimit      int64               `json:"upload_limit"`
+	SeedPolicy       *SeedPolicyResponse `json:"seed_policy,omitempty"`
+	PeerUploaded     int64               `json:"peer_uploaded"`
+	Ratio            float64             `json:"ratio"`
+	SeedingStartedAt *string             `json:"seeding_started_at,omitempty"`
+	UploadDoneBytes  int64               `json:"upload_done_bytes"`
+	UploadTotalBytes int64               `json:"upload_total_bytes"`
+	UploadSpeed      int64               `json:"upload_speed"`
+	UploadProgress   int                 `json:"upload_progress"`
+	Attempts         int                 `json:"attempts"`
+	NextRetryAt      *string             `json:"next_retry_at,omitempty"`
+	QueuePriority    string              `json:"queue_priority"`
+	QueuePosition    int64               `json:"queue_position"`
+	DisplayName      string              `json:"display_name"`
+	ExpectedSize     int64               `json:"expected_size"`
+	Files            []TaskFileResponse  `json:"files"`
+}
+
+// cleanupLocalData removes the downloaded data of a task. Torrent data that
+// another user's task for the same torrent may still use is left alone; only
+// the task's own task-<id> directory goes.
+func (h *Handler) cleanupLocalData(ctx context.Context, task *domain.Task) []string {
+	root := filepath.Clean(h.dataRoot)
+	seen := make(map[string]struct{})
+	var warnings []string
+
+	shared, err := h.tasks.TorrentShared(ctx, task)
+	if err != nil {
+		return []string{fmt.Sprintf("check shared torrent data: %v", err)}
+	}
+	if shared && !strings.HasPrefix(filepath.Base(task.LocalPath), "task-") {
+		return nil
+	}
+
+	addPath := func(p string, restrictToRoot bool) {
+		if p == "" {
+			return
+		}
+		clean := filepath.Clean(p)
+		if clean == "" || clean == "." {
+			return
+		}
+		if restrictToRoot {
+			if root == "" {
+				return
+			}
+			if rel, err := filepath.Rel(root, clean); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+				return
+			}
+		} else if root != "" && clean == root {
+			return
+		}
+		if _, ok := seen[clean]; ok {
+			return
+		}
+		seen[clean] = struct{}{}
+		if err := os.RemoveAll(clean); err != nil && !os.IsNotExist(err) {
+			warnings = append(warnings, fmt.Sprintf("remove local data %s: %v", clean, err))
+		}
+	}
+
+	addPath(task.LocalPath, false)
+	if shared {
+		return warnings
+	}
+	if infoHash, err := domain.MagnetInfoHash(task.MagnetURI); err == nil {
+		addPath(filepath.Join(root, infoHash), true)
+	}
+
+	return warnings
+}
+
+type TaskEventResponse struct {
+	ID               uint64                  `json:"id"`
+	Type             string                  `json:"type"`
+	TaskID           int64                   `json:"task_id,omitempty"`
+	Status           domain.TaskStatus       `json:"status,omitempty"`
+	Progress         int                     `json:"progress"`
+	Speed            int64                   `json:"speed"`
+	DownloadedBytes  int64                   `json:"downloaded_bytes"`
+	TotalSize        int64                   `json:"total_size"`
+	TotalPeers       int                     `json:"total_peers"`
+	ActivePeers      int                     `json:"active_peers"`
+	PendingPeers     int                     `json:"pending_peers"`
+	ConnectedSeeders int                     `json:"connected_seeders"`
+	HalfOpenPeers    int                     `json:"half_open_peers"`
+	PeerUploaded     int64                   `json:"peer_uploaded"`
+	UploadDoneBytes  int64                   `json:"upload_done_bytes"`
+	UploadTotalBytes int64                   `json:"upload_total_bytes"`
+	UploadSpeed      int64                   `json:"upload_speed"`
+	FileName         string                  `json:"file_name,omitempty"`
+	FileUploadStatus domain.FileUploadStatus `json:"file_upload_status,omitempty"`
+	TorrentName      string                  `json:"torrent_name,omitempty"`
+	S3Location       string                  `json:"s3_location,omitempty"`
+	ErrorMessage     string                  `json:"error_message,omitempty"`
+	Attempts         int                     `json:"attempts,omitempty"`
+	NextRetryAt      *string                 `json:"next_retry_at,omitempty"`
+	Time             string                  `json:"time"`
+}
+
+func taskEventToResponse(evt events.TaskEvent) TaskEventResponse {
+	resp := TaskEventResponse{
+		ID:               evt.ID,
+		Type:             evt.Type,
+		TaskID:           evt.TaskID,
+		Status:           evt.Status,
+		Progress:         evt.Progress,
+		Speed:            evt.Speed,
+		DownloadedBytes:  evt.DownloadedBytes,
+		TotalSize:        evt.TotalSize,
+		TotalPeers:       evt.TotalPeers,
+		ActivePeers:      evt.ActivePeers,
+		PendingPeers:     evt.PendingPeers,
+		ConnectedSeeders: evt.ConnectedSeeders,
+		HalfOpenPeers:    evt.HalfOpenPeers,
+		PeerUploaded:     evt.PeerUploaded,
+		UploadDoneBytes:  evt.UploadDoneBytes,
+		UploadTotalBytes: evt.UploadTotalBytes,
+		UploadSpeed:      evt.UploadSpeed,
+		FileName:         evt.FileName,
+		FileUploadStatus: evt.FileUploadStatus,
+		TorrentName:      evt.TorrentName,
+		S3Location:       evt.S3Location,
+		ErrorMessage:     evt.ErrorMessage,
+		Attempts:         evt.Attempts,
+		Time:             evt.Time.Format(time.RFC3339),
+	}
+	if evt.NextRetryAt != nil {
+		v := evt.NextRetryAt.Format(time.RFC3339)
+		resp.NextRetryAt = &v
+	}
+	return resp
+}
+
+type TaskFileResponse struct {
+	ID           int64                   `json:"id"`
+	TaskID       int64                   `json:"task_id"`
+	Name         string                  `json:"name"`
+	Path         string                  `json:"path"`
+	Size         int64                   `json:"size"`
+	Priority     string                  `json:"priority"`
+	UploadStatus domain.FileUploadStatus `json:"upload_status,omitempty"`
 }
 
 type StorageObjectResponse struct {
@@ -581,6 +1586,7 @@ func taskToResponse(task domain.Task) TaskResponse {
 	resp := TaskResponse{
 		ID:               task.ID,
 		Magnet:           task.MagnetURI,
+		InfoHash:         task.InfoHash,
 		Status:           task.Status,
 		Progress:         task.Progress,
 		Speed:            task.Speed,
@@ -597,6 +1603,18 @@ func taskToResponse(task domain.Task) TaskResponse {
 		ErrorMessage:     task.ErrorMessage,
 		CreatedAt:        task.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:        task.UpdatedAt.Format(time.RFC3339),
+		DownloadLimit:    task.DownloadLimit,
+		UploadLimit:      task.UploadLimit,
+		PeerUploaded:     task.PeerUploaded,
+		Ratio:            task.Ratio(),
+		UploadDoneBytes:  task.UploadDoneBytes,
+		UploadTotalBytes: task.UploadTotalBytes,
+		UploadSpeed:      task.UploadSpeed,
+		Attempts:         task.Attempts,
+		QueuePriority:    domain.QueuePriorityName(task.QueuePriority),
+		QueuePosition:    task.QueuePosition,
+		DisplayName:      task.DisplayName,
+		ExpectedSize:     task.ExpectedSize,
 		Files:            make([]TaskFileResponse, len(task.Files)),
 	}
 	if task.DownloadedAt != nil {
@@ -607,21 +1625,50 @@ func taskToResponse(task domain.Task) TaskResponse {
 		v := task.UploadedAt.Format(time.RFC3339)
 		resp.UploadedAt = &v
 	}
+	if task.UploadTotalBytes > 0 {
+		resp.UploadProgress = int(task.UploadDoneBytes * 100 / task.UploadTotalBytes)
+	}
+	if task.SeedingStartedAt != nil {
+		v := task.SeedingStartedAt.Format(time.RFC3339)
+		resp.SeedingStartedAt = &v
+	}
+	if task.NextRetryAt != nil {
+		v := task.NextRetryAt.Format(time.RFC3339)
+		resp.NextRetryAt = &v
+	}
+	if task.SeedPolicy != nil {
+		resp.SeedPolicy = &SeedPolicyResponse{
+			Mode:            task.SeedPolicy.Mode,
+			Ratio:           task.SeedPolicy.Ratio,
+			DurationMinutes: int(task.SeedPolicy.Duration / time.Minute),
+		}
+	}
 
 	for i := range task.Files {
 		resp.Files[i] = TaskFileResponse{
-			ID:       task.Files[i].ID,
-			TaskID:   task.Files[i].TaskID,
-			Name:     task.Files[i].Name,
-			Path:     task.Files[i].Path,
-			Size:     task.Files[i].Size,
-			Priority: task.Files[i].Priority,
+			ID:           task.Files[i].ID,
+			TaskID:       task.Files[i].TaskID,
+			Name:         task.Files[i].Name,
+			Path:         task.Files[i].Path,
+			Size:         task.Files[i].Size,
+			Priority:     domain.FilePriorityName(task.Files[i].Priority),
+			UploadStatus: task.Files[i].UploadStatus,
 		}
 	}
 	return resp
 }
 
-func extractS3Prefix(location, bucket string) (string, error) {
+func extractStoragePrefix(location, bucket string) (string, error) {
+	for _, scheme := range []string{storage.LocalScheme, storage.WebDAVScheme} {
+		if !strings.HasPrefix(location, scheme) {
+			continue
+		}
+		prefix := strings.Trim(strings.TrimPrefix(location, scheme), "/")
+		if prefix == "" {
+			return "", fmt.Errorf("storage prefix missing")
+		}
+		return prefix, nil
+	}
 	if !strings.HasPrefix(location, "s3://") {
 		return "", fmt.Errorf("invalid s3 location")
 	}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/service"
+)
+
+type createAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes"`
+}
+
+type APIKeyResponse struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	LastUsedAt *string  `json:"last_used_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+	// Key is only returned when the key is created.
+	Key string `json:"key,omitempty"`
+}
+
+func (h *Handler) listAPIKeys(c *gin.Context) {
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
+		return
+	}
+
+	keys, err := h.users.ListAPIKeys(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]APIKeyResponse, len(keys))
+	for i := range keys {
+		resp[i] = apiKeyToResponse(keys[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) createAPIKey(c *gin.Context) {
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
+		return
+	}
+
+	var req createAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scopes := make([]domain.APIKeyScope, 0, len(req.Scopes))
+	for _, name := range req.Scopes {
+		scope, err := domain.ParseAPIKeyScope(name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		scopes = append(scopes, scope)
+	}
+
+	key, rawKey, err := h.users.CreateAPIKey(c.Request.Context(), user.ID, req.Name, scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := apiKeyToResponse(*key)
+	resp.Key = rawKey
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *Handler) revokeAPIKey(c *gin.Context) {
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid api key id"})
+		return
+	}
+
+	if err := h.users.RevokeAPIKey(c.Request.Context(), user.ID, id); err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func apiKeyToResponse(key domain.APIKey) APIKeyResponse {
+	resp := APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    make([]string, len(key.Scopes)),
+		CreatedAt: key.CreatedAt.Format(time.RFC3339),
+	}
+	for i, scope := range key.Scopes {
+		resp.Scopes[i] = string(scope)
+	}
+	if key.LastUsedAt != nil {
+		lastUsed := key.LastUsedAt.Format(time.RFC3339)
+		resp.LastUsedAt = &lastUsed
+	}
+	return resp
+}
@@ -0,0 +1,390 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/events"
+	"magnet-player/internal/service"
+)
+
+const (
+	// maxBatchMagnets caps the magnets accepted by one batch request.
+	maxBatchMagnets = 100
+	// maxBulkTasks caps the tasks, given by id or by status, that one bulk
+	// request acts on.
+	maxBulkTasks = 500
+	// maxBatchBodySize limits a newline separated batch body.
+	maxBatchBodySize = 1 << 20
+)
+
+// Bulk actions understood by POST /tasks/bulk.
+const (
+	bulkActionDelete = "delete"
+	bulkActionPause  = "pause"
+	bulkActionResume = "resume"
+	bulkActionRetry  = "retry"
+)
+
+// Outcomes of a single item in a batch or bulk request.
+const (
+	batchCreated   = "created"
+	batchDuplicate = "duplicate"
+	batchInvalid   = "invalid"
+	batchFailed    = "failed"
+	bulkDone       = "ok"
+)
+
+type createTaskBatchRequest struct {
+	Magnets []string `json:"magnets" binding:"required"`
+}
+
+type bulkTaskRequest struct {
+	Action string   `json:"action" binding:"required"`
+	IDs    []int64  `json:"ids"`
+	Status []string `json:"status"`
+	// DeleteRemote also removes the uploaded data of deleted tasks.
+	DeleteRemote bool `json:"delete_remote"`
+}
+
+type BatchTaskResult struct {
+	Magnet string            `json:"magnet"`
+	Status string            `json:"status"`
+	TaskID int64             `json:"task_id,omitempty"`
+	Task   *TaskResponse     `json:"task,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+type BatchTaskResponse struct {
+	Results []BatchTaskResult `json:"results"`
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+}
+
+type BulkTaskResult struct {
+	TaskID   int64         `json:"task_id"`
+	Status   string        `json:"status"`
+	Task     *TaskResponse `json:"task,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
+}
+
+type BulkTaskResponse struct {
+	Action    string           `json:"action"`
+	Results   []BulkTaskResult `json:"results"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+}
+
+// createTaskBatch adds many magnets at once. The body is either JSON with a
+// magnets list or plain text with one magnet per line. Each magnet is created
+// on its own, so one bad line does not reject the others.
+func (h *Handler) createTaskBatch(c *gin.Context) {
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
+		return
+	}
+
+	magnets, err := readBatchMagnets(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(magnets) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no magnets given"})
+		return
+	}
+	if len(magnets) > maxBatchMagnets {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d magnets per batch", maxBatchMagnets)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp := BatchTaskResponse{Results: make([]BatchTaskResult, len(magnets))}
+	for i, magnet := range magnets {
+		result := h.createBatchTask(ctx, user, magnet)
+		if result.Status == batchCreated {
+			resp.Created++
+		} else {
+			resp.Failed++
+		}
+		resp.Results[i] = result
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) createBatchTask(ctx context.Context, user *domain.User, magnet string) BatchTaskResult {
+	result := BatchTaskResult{Magnet: magnet}
+
+	task, err := h.tasks.CreateTask(ctx, user.ID, magnet, h.dataRoot)
+	if err != nil {
+		var (
+			dup       *service.DuplicateTaskError
+			magnetErr *domain.MagnetError
+		)
+		switch {
+		case errors.As(err, &dup) && dup.Task.UserID == user.ID:
+			// best effort, as for a single duplicate
+			_ = h.manager.ApplyTrackers(ctx, dup.Task.ID)
+			result.Status = batchDuplicate
+			result.Error = "task already exists"
+			result.TaskID = dup.Task.ID
+		case errors.As(err, &magnetErr):
+			result.Status = batchInvalid
+			result.Error = err.Error()
+			result.Fields = magnetErr.Fields
+		default:
+			result.Status = batchFailed
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	result.TaskID = task.ID
+	if err := h.startTask(ctx, task); err != nil {
+		result.Status = batchFailed
+		result.Error = err.Error()
+		return result
+	}
+	resp := taskToResponse(*task)
+	result.Status = batchCreated
+	result.Task = &resp
+	return result
+}
+
+// readBatchMagnets reads the magnets of a batch request. Blank lines and
+// lines starting with # are skipped in plain text bodies.
+func readBatchMagnets(c *gin.Context) ([]string, error) {
+	if c.ContentType() == "application/json" {
+		var req createTaskBatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return nil, err
+		}
+		magnets := make([]string, 0, len(req.Magnets))
+		for _, magnet := range req.Magnets {
+			if magnet = strings.TrimSpace(magnet); magnet != "" {
+				magnets = append(magnets, magnet)
+			}
+		}
+		return magnets, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBatchBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+	if len(data) > maxBatchBodySize {
+		return nil, fmt.Errorf("request body exceeds %d bytes", maxBatchBodySize)
+	}
+	var magnets []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		magnets = append(magnets, line)
+	}
+	return magnets, nil
+}
+
+// bulkTaskAction deletes, pauses, resumes or retries the tasks named by ids
+// or matched by a status filter. Deletes are committed in one transaction;
+// the state changes go through the downloader one task at a time.
+func (h *Handler) bulkTaskAction(c *gin.Context) {
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
+		return
+	}
+
+	var req bulkTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	action := strings.ToLower(strings.TrimSpace(req.Action))
+	var change func(ctx context.Context, taskID int64) error
+	switch action {
+	case bulkActionDelete:
+		if req.DeleteRemote && h.storage == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "storage service not configured"})
+			return
+		}
+	case bulkActionPause:
+		change = h.manager.Pause
+	case bulkActionResume:
+		change = h.manager.ResumeTask
+	case bulkActionRetry:
+		change = h.manager.Retry
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown bulk action %q", req.Action)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	resp := BulkTaskResponse{Action: action}
+	tasks, missing, err := h.bulkTargets(ctx, user, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if !errors.Is(err, errInvalidBulkTargets) {
+			status = http.StatusInternalServerError
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	for _, id := range missing {
+		resp.Results = append(resp.Results, BulkTaskResult{TaskID: id, Status: batchFailed, Error: service.ErrTaskNotFound.Error()})
+	}
+
+	var results []BulkTaskResult
+	if action == bulkActionDelete {
+		results, err = h.bulkDelete(ctx, tasks, req.DeleteRemote)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		results = h.bulkChangeState(ctx, tasks, change)
+	}
+	resp.Results = append(resp.Results, results...)
+
+	for _, result := range resp.Results {
+		if result.Status == bulkDone {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+	}
+	if resp.Results == nil {
+		resp.Results = []BulkTaskResult{}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+var errInvalidBulkTargets = errors.New("invalid bulk targets")
+
+// bulkTargets resolves the tasks of a bulk request. Ids that do not name a
+// task of the user are returned as missing.
+func (h *Handler) bulkTargets(ctx context.Context, user *domain.User, req bulkTaskRequest) ([]domain.Task, []int64, error) {
+	switch {
+	case len(req.IDs) > 0 && len(req.Status) > 0:
+		return nil, nil, fmt.Errorf("%w: give either ids or status, not both", errInvalidBulkTargets)
+	case len(req.Status) > 0:
+		statuses := make([]domain.TaskStatus, len(req.Status))
+		for i, name := range req.Status {
+			status, err := domain.ParseTaskStatus(name)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%w: %v", errInvalidBulkTargets, err)
+			}
+			statuses[i] = status
+		}
+		tasks, err := h.tasks.ListUserTasksByStatuses(ctx, user.ID, statuses...)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(tasks) > maxBulkTasks {
+			return nil, nil, fmt.Errorf("%w: status matches %d tasks, at most %d per request", errInvalidBulkTargets, len(tasks), maxBulkTasks)
+		}
+		return tasks, nil, nil
+	case len(req.IDs) > 0:
+		if len(req.IDs) > maxBulkTasks {
+			return nil, nil, fmt.Errorf("%w: at most %d ids per request", errInvalidBulkTargets, maxBulkTasks)
+		}
+		var (
+			tasks   []domain.Task
+			missing []int64
+		)
+		seen := make(map[int64]struct{}, len(req.IDs))
+		for _, id := range req.IDs {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			task, err := h.tasks.GetUserTask(ctx, user.ID, id)
+			if err != nil {
+				if errors.Is(err, service.ErrTaskNotFound) {
+					missing = append(missing, id)
+					continue
+				}
+				return nil, nil, err
+			}
+			tasks = append(tasks, *task)
+		}
+		return tasks, missing, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: ids or status is required", errInvalidBulkTargets)
+	}
+}
+
+// bulkDelete stops the tasks and removes their data, then deletes every task
+// whose data could be handled in a single transaction.
+func (h *Handler) bulkDelete(ctx context.Context, tasks []domain.Task, deleteRemote bool) ([]BulkTaskResult, error) {
+	results := make([]BulkTaskResult, len(tasks))
+	var ids []int64
+	for i := range tasks {
+		task := &tasks[i]
+		results[i].TaskID = task.ID
+		warnings, err := h.discardTaskData(ctx, task, deleteRemote)
+		if err != nil {
+			results[i].Status = batchFailed
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Warnings = warnings
+		ids = append(ids, task.ID)
+	}
+
+	if err := h.tasks.DeleteTasks(ctx, ids); err != nil {
+		return nil, err
+	}
+
+	for i := range tasks {
+		if results[i].Status == batchFailed {
+			continue
+		}
+		results[i].Status = bulkDone
+		h.events.Publish(events.TaskEvent{
+			Type:   events.TypeDeleted,
+			TaskID: tasks[i].ID,
+			UserID: tasks[i].UserID,
+		})
+	}
+	return results, nil
+}
+
+// bulkChangeState applies a downloader action to each task. Failures, such as
+// a task in a state the action does not apply to, are reported per task.
+func (h *Handler) bulkChangeState(ctx context.Context, tasks []domain.Task, change func(ctx context.Context, taskID int64) error) []BulkTaskResult {
+	results := make([]BulkTaskResult, len(tasks))
+	for i := range tasks {
+		id := tasks[i].ID
+		results[i].TaskID = id
+
+		actionCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := change(actionCtx, id)
+		cancel()
+		if err != nil {
+			results[i].Status = batchFailed
+			results[i].Error = err.Error()
+			continue
+		}
+
+		results[i].Status = bulkDone
+		if task, err := h.tasks.GetTask(ctx, id); err == nil {
+			resp := taskToResponse(*task)
+			results[i].Task = &resp
+		}
+	}
+	return results
+}
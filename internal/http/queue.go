@@ -0,0 +1,151 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/service"
+)
+
+type updateTaskQueueRequest struct {
+	Priority string `json:"priority" binding:"required"`
+}
+
+type updateQueueSettingsRequest struct {
+	MaxActive int `json:"max_active" binding:"required"`
+}
+
+type QueueSettingsResponse struct {
+	MaxActive int `json:"max_active"`
+}
+
+func (h *Handler) listQueue(c *gin.Context) {
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
+		return
+	}
+
+	tasks, err := h.tasks.ListQueue(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp := make([]TaskResponse, len(tasks))
+	for i := range tasks {
+		resp[i] = taskToResponse(tasks[i])
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) updateTaskQueue(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+
+	var req updateTaskQueueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	priority, err := domain.ParseQueuePriority(req.Priority)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.userTask(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.tasks.SetQueuePriority(c.Request.Context(), task.ID, priority); err != nil {
+		if errors.Is(err, service.ErrTaskNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.respondQueuedTask(c, task.ID)
+}
+
+func (h *Handler) moveTaskInQueue(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+	move, err := domain.ParseQueueMove(c.Param("move"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.userTask(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.tasks.MoveInQueue(c.Request.Context(), task.ID, move); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrTaskNotQueued):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	h.respondQueuedTask(c, task.ID)
+}
+
+// respondQueuedTask applies a stored queue change to the waiting downloads
+// and responds with the updated task.
+func (h *Handler) respondQueuedTask(c *gin.Context, id int64) {
+	if err := h.manager.ApplyQueueOrder(c.Request.Context()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.tasks.GetTask(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, taskToResponse(*task))
+}
+
+func (h *Handler) getQueueSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, QueueSettingsResponse{MaxActive: h.manager.MaxActive()})
+}
+
+func (h *Handler) updateQueueSettings(c *gin.Context) {
+	var req updateQueueSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.MaxActive < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_active must be at least 1"})
+		return
+	}
+
+	if err := h.settings.SaveMaxActive(c.Request.Context(), req.MaxActive); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.manager.SetMaxActive(req.MaxActive)
+
+	c.JSON(http.StatusOK, QueueSettingsResponse{MaxActive: req.MaxActive})
+}
@@ -0,0 +1,233 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/service"
+)
+
+type bandwidthScheduleRequest struct {
+	Enabled       bool     `json:"enabled"`
+	DownloadLimit int64    `json:"download_limit"`
+	UploadLimit   int64    `json:"upload_limit"`
+	Start         string   `json:"start"`
+	End           string   `json:"end"`
+	Days          []string `json:"days"`
+}
+
+type updateBandwidthRequest struct {
+	DownloadLimit int64 `json:"download_limit"`
+	UploadLimit   int64 `json:"upload_limit"`
+	// Schedule keeps the current schedule when omitted.
+	Schedule *bandwidthScheduleRequest `json:"schedule"`
+}
+
+type updateTaskBandwidthRequest struct {
+	DownloadLimit int64 `json:"download_limit"`
+	UploadLimit   int64 `json:"upload_limit"`
+}
+
+type updateTaskSeedingRequest struct {
+	// Mode is a seeding mode, or "default" to follow the server default.
+	Mode            string  `json:"mode" binding:"required"`
+	Ratio           float64 `json:"ratio"`
+	DurationMinutes int     `json:"duration_minutes"`
+}
+
+type SeedPolicyResponse struct {
+	Mode            domain.SeedMode `json:"mode"`
+	Ratio           float64         `json:"ratio,omitempty"`
+	DurationMinutes int             `json:"duration_minutes,omitempty"`
+}
+
+type BandwidthScheduleResponse struct {
+	Enabled       bool     `json:"enabled"`
+	DownloadLimit int64    `json:"download_limit"`
+	UploadLimit   int64    `json:"upload_limit"`
+	Start         string   `json:"start"`
+	End           string   `json:"end"`
+	Days          []string `json:"days"`
+}
+
+type BandwidthResponse struct {
+	DownloadLimit int64                     `json:"download_limit"`
+	UploadLimit   int64                     `json:"upload_limit"`
+	Schedule      BandwidthScheduleResponse `json:"schedule"`
+	// ScheduleActive reports whether the scheduled limits are in effect right now.
+	ScheduleActive bool `json:"schedule_active"`
+}
+
+func (h *Handler) getBandwidth(c *gin.Context) {
+	c.JSON(http.StatusOK, bandwidthToResponse(h.manager.Bandwidth(), time.Now()))
+}
+
+func (h *Handler) updateBandwidth(c *gin.Context) {
+	var req updateBandwidthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings := h.manager.Bandwidth()
+	settings.Limits = domain.BandwidthLimits{Download: req.DownloadLimit, Upload: req.UploadLimit}
+	if req.Schedule != nil {
+		schedule, err := parseBandwidthSchedule(*req.Schedule)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		settings.Schedule = schedule
+	}
+	if err := settings.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.settings.SaveBandwidth(c.Request.Context(), settings); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.manager.SetBandwidth(settings)
+
+	c.JSON(http.StatusOK, bandwidthToResponse(settings, time.Now()))
+}
+
+func (h *Handler) updateTaskBandwidth(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+
+	var req updateTaskBandwidthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := h.userTask(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	limits := domain.BandwidthLimits{Download: req.DownloadLimit, Upload: req.UploadLimit}
+	if err := h.tasks.SetRateLimits(c.Request.Context(), task.ID, limits); err != nil {
+		if errors.Is(err, service.ErrTaskNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.manager.ApplyTaskBandwidth(c.Request.Context(), task.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err = h.tasks.GetTask(c.Request.Context(), task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, taskToResponse(*task))
+}
+
+func (h *Handler) updateTaskSeeding(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+
+	var req updateTaskSeedingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var policy *domain.SeedingPolicy
+	if !strings.EqualFold(strings.TrimSpace(req.Mode), "default") {
+		mode, err := domain.ParseSeedMode(req.Mode)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		policy = &domain.SeedingPolicy{
+			Mode:     mode,
+			Ratio:    req.Ratio,
+			Duration: time.Duration(req.DurationMinutes) * time.Minute,
+		}
+	}
+
+	task, err := h.userTask(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.tasks.SetSeedPolicy(c.Request.Context(), task.ID, policy); err != nil {
+		if errors.Is(err, service.ErrTaskNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.manager.ApplySeedingPolicy(c.Request.Context(), task.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err = h.tasks.GetTask(c.Request.Context(), task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, taskToResponse(*task))
+}
+
+func parseBandwidthSchedule(req bandwidthScheduleRequest) (domain.BandwidthSchedule, error) {
+	schedule := domain.BandwidthSchedule{
+		Enabled: req.Enabled,
+		Limits:  domain.BandwidthLimits{Download: req.DownloadLimit, Upload: req.UploadLimit},
+	}
+	var err error
+	if schedule.Start, err = domain.ParseClock(req.Start); err != nil {
+		return domain.BandwidthSchedule{}, err
+	}
+	if schedule.End, err = domain.ParseClock(req.End); err != nil {
+		return domain.BandwidthSchedule{}, err
+	}
+	if schedule.Days, err = domain.ParseWeekdays(req.Days); err != nil {
+		return domain.BandwidthSchedule{}, err
+	}
+	return schedule, nil
+}
+
+func bandwidthToResponse(settings domain.BandwidthSettings, now time.Time) BandwidthResponse {
+	days := make([]string, len(settings.Schedule.Days))
+	for i, day := range settings.Schedule.Days {
+		days[i] = strings.ToLower(day.String())
+	}
+	return BandwidthResponse{
+		DownloadLimit: settings.Limits.Download,
+		UploadLimit:   settings.Limits.Upload,
+		Schedule: BandwidthScheduleResponse{
+			Enabled:       settings.Schedule.Enabled,
+			DownloadLimit: settings.Schedule.Limits.Download,
+			UploadLimit:   settings.Schedule.Limits.Upload,
+			Start:         domain.FormatClock(settings.Schedule.Start),
+			End:           domain.FormatClock(settings.Schedule.End),
+			Days:          days,
+		},
+		ScheduleActive: settings.Schedule.Active(now),
+	}
+}
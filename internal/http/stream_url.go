@@ -0,0 +1,124 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamRoute is the full path of the file stream endpoint. Media elements
+// cannot send an Authorization header, so it also accepts the signed query
+// produced by taskFileStreamURL.
+const streamRoute = "/api/tasks/:id/files/:fileId/stream"
+
+type StreamURLResponse struct {
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// taskFileStreamURL responds with a short-lived signed URL for streaming a
+// task file. The optional ttl (seconds) query parameter tunes its lifetime.
+func (h *Handler) taskFileStreamURL(c *gin.Context) {
+	user, ok := userFromContext(c)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user context missing"})
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task id"})
+		return
+	}
+	fileID, err := strconv.ParseInt(c.Param("fileId"), 10, 64)
+	if err != nil || fileID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file id"})
+		return
+	}
+	ttl, err := parsePresignTTL(c.Query("ttl"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(h.jwtSecret) == 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "jwt secret not configured"})
+		return
+	}
+
+	task, err := h.userTask(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := findTaskFile(task, fileID); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task file not found"})
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	expires := expiresAt.Unix()
+	query := url.Values{}
+	query.Set("uid", strconv.FormatInt(user.ID, 10))
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", h.signStream(user.ID, task.ID, fileID, expires))
+
+	c.JSON(http.StatusOK, StreamURLResponse{
+		URL:       fmt.Sprintf("/api/tasks/%d/files/%d/stream?%s", task.ID, fileID, query.Encode()),
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	})
+}
+
+// authenticateStreamURL authorizes a stream request with the signature made
+// by taskFileStreamURL and continues the chain.
+func (h *Handler) authenticateStreamURL(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Query("uid"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid stream signature"})
+		return
+	}
+	taskID, _ := strconv.ParseInt(c.Param("id"), 10, 64)
+	fileID, _ := strconv.ParseInt(c.Param("fileId"), 10, 64)
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires || len(h.jwtSecret) == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired stream signature"})
+		return
+	}
+	expected := h.signStream(userID, taskID, fileID, expires)
+	if !hmac.Equal([]byte(expected), []byte(c.Query("signature"))) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired stream signature"})
+		return
+	}
+
+	if h.users == nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "user service not configured"})
+		return
+	}
+	user, err := h.users.GetByID(c.Request.Context(), userID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token user"})
+		return
+	}
+	if user.Disabled {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "account disabled"})
+		return
+	}
+
+	c.Set(contextUserKey, user)
+	c.Next()
+}
+
+// signStream signs a stream URL with a key derived from the JWT secret, so a
+// stream signature can never pass as a session token.
+func (h *Handler) signStream(userID, taskID, fileID, expires int64) string {
+	derive := hmac.New(sha256.New, h.jwtSecret)
+	derive.Write([]byte("magnet-player stream url"))
+	mac := hmac.New(sha256.New, derive.Sum(nil))
+	fmt.Fprintf(mac, "%d\n%d\n%d\n%d", userID, taskID, fileID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"magnet-player/internal/domain"
+)
+
+// SessionRepository persists login sessions and their refresh tokens.
+type SessionRepository interface {
+	Init(ctx context.Context) error
+	Create(ctx context.Context, session *domain.Session) error
+	Get(ctx context.Context, id string) (*domain.Session, error)
+	Rotate(ctx context.Context, id, oldHash, newHash string) error
+	Revoke(ctx context.Context, id string) error
+	RevokeAllForUser(ctx context.Context, userID int64) error
+	// DeleteInactive removes sessions that expired or were revoked before now.
+	DeleteInactive(ctx context.Context, now time.Time) (int64, error)
+}
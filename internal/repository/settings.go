@@ -0,0 +1,11 @@
+package repository
+
+import "context"
+
+// SettingsRepository persists runtime settings changed through the API as
+// key/value pairs, so they survive restarts and override config defaults.
+type SettingsRepository interface {
+	Init(ctx context.Context) error
+	List(ctx context.Context) (map[string]string, error)
+	Set(ctx context.Context, values map[string]string) error
+}
@@ -0,0 +1,163 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/repository"
+)
+
+const createSessionsTable = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	refresh_token_hash TEXT NOT NULL,
+	expires_at DATETIME NOT NULL,
+	revoked_at DATETIME NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+`
+
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) repository.SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) Init(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, createSessionsTable); err != nil {
+		return fmt.Errorf("create sessions table: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	now := time.Now().UTC()
+	session.CreatedAt = now
+	session.UpdatedAt = now
+
+	_, err := r.db.ExecContext(ctx, `
+INSERT INTO sessions (id, user_id, refresh_token_hash, expires_at, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)`,
+		session.ID,
+		session.UserID,
+		session.RefreshTokenHash,
+		session.ExpiresAt.UTC(),
+		session.CreatedAt,
+		session.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) Get(ctx context.Context, id string) (*domain.Session, error) {
+	var (
+		session   domain.Session
+		revokedAt sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, `
+SELECT id, user_id, refresh_token_hash, expires_at, revoked_at, created_at, updated_at
+FROM sessions
+WHERE id = ?`,
+		id,
+	).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.RefreshTokenHash,
+		&session.ExpiresAt,
+		&revokedAt,
+		&session.CreatedAt,
+		&session.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("scan session: %w", err)
+	}
+	if revokedAt.Valid {
+		t := revokedAt.Time
+		session.RevokedAt = &t
+	}
+	return &session, nil
+}
+
+// Rotate swaps the refresh token hash only if oldHash is still current, so
+// two concurrent refreshes with the same token cannot both succeed.
+func (r *SessionRepository) Rotate(ctx context.Context, id, oldHash, newHash string) error {
+	res, err := r.db.ExecContext(ctx, `
+UPDATE sessions
+SET refresh_token_hash = ?, updated_at = ?
+WHERE id = ? AND refresh_token_hash = ? AND revoked_at IS NULL`,
+		newHash,
+		time.Now().UTC(),
+		id,
+		oldHash,
+	)
+	if err != nil {
+		return fmt.Errorf("rotate session: %w", err)
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("session rotate rows affected: %w", err)
+	}
+	if aff == 0 {
+		return fmt.Errorf("session not found")
+	}
+	return nil
+}
+
+func (r *SessionRepository) Revoke(ctx context.Context, id string) error {
+	now := time.Now().UTC()
+	if _, err := r.db.ExecContext(ctx, `
+UPDATE sessions
+SET revoked_at = ?, updated_at = ?
+WHERE id = ? AND revoked_at IS NULL`,
+		now,
+		now,
+		id,
+	); err != nil {
+		return fmt.Errorf("revoke session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) DeleteInactive(ctx context.Context, now time.Time) (int64, error) {
+	now = now.UTC()
+	res, err := r.db.ExecContext(ctx, `
+DELETE FROM sessions
+WHERE expires_at < ? OR (revoked_at IS NOT NULL AND revoked_at < ?)`,
+		now,
+		now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("delete inactive sessions: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (r *SessionRepository) RevokeAllForUser(ctx context.Context, userID int64) error {
+	now := time.Now().UTC()
+	if _, err := r.db.ExecContext(ctx, `
+UPDATE sessions
+SET revoked_at = ?, updated_at = ?
+WHERE user_id = ? AND revoked_at IS NULL`,
+		now,
+		now,
+		userID,
+	); err != nil {
+		return fmt.Errorf("revoke user sessions: %w", err)
+	}
+	return nil
+}
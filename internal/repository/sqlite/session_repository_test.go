@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/repository"
+)
+
+// newUserRepos opens a fresh database with the user and session tables and
+// creates the user alice.
+func newUserRepos(t *testing.T) (*sql.DB, repository.UserRepository, repository.SessionRepository, int64) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	users := NewUserRepository(db)
+	if err := users.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sessions := NewSessionRepository(db)
+	if err := sessions.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	userID, err := users.Create(ctx, &domain.User{Username: "alice", PasswordHash: "x", Role: domain.UserRoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, users, sessions, userID
+}
+
+func TestDeleteInactiveSessions(t *testing.T) {
+	ctx := context.Background()
+	_, _, sessions, userID := newUserRepos(t)
+
+	now := time.Now()
+	for id, expiresAt := range map[string]time.Time{
+		"active":  now.Add(time.Hour),
+		"expired": now.Add(-time.Minute),
+		"revoked": now.Add(time.Hour),
+	} {
+		if err := sessions.Create(ctx, &domain.Session{ID: id, UserID: userID, RefreshTokenHash: "hash", ExpiresAt: expiresAt}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sessions.Revoke(ctx, "revoked"); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := sessions.DeleteInactive(ctx, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("DeleteInactive: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d sessions, want 2", deleted)
+	}
+	if _, err := sessions.Get(ctx, "active"); err != nil {
+		t.Errorf("active session was deleted: %v", err)
+	}
+	for _, id := range []string{"expired", "revoked"} {
+		if _, err := sessions.Get(ctx, id); err == nil {
+			t.Errorf("session %s was kept", id)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"magnet-player/internal/repository"
+)
+
+const createSettingsTable = `
+CREATE TABLE IF NOT EXISTS settings (
+	key TEXT PRIMARY KEY,
+	value TEXT NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+`
+
+type SettingsRepository struct {
+	db *sql.DB
+}
+
+func NewSettingsRepository(db *sql.DB) repository.SettingsRepository {
+	return &SettingsRepository{db: db}
+}
+
+func (r *SettingsRepository) Init(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, createSettingsTable); err != nil {
+		return fmt.Errorf("create settings table: %w", err)
+	}
+	return nil
+}
+
+func (r *SettingsRepository) List(ctx context.Context) (map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT key, value FROM settings`)
+	if err != nil {
+		return nil, fmt.Errorf("query settings: %w", err)
+	}
+	defer rows.Close()
+
+	values := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("scan setting: %w", err)
+		}
+		values[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Set upserts all values in a single transaction.
+func (r *SettingsRepository) Set(ctx context.Context, values map[string]string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback() // safe no-op on commit
+
+	now := time.Now().UTC()
+	for key, value := range values {
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO settings (key, value, updated_at)
+VALUES (?, ?, ?)
+ON CONFLICT(key) DO UPDATE SET
+	value = excluded.value,
+	updated_at = excluded.updated_at`,
+			key,
+			value,
+			now,
+		); err != nil {
+			return fmt.Errorf("save setting %s: %w", key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit settings: %w", err)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"magnet-player/internal/domain"
 	"magnet-player/internal/repository"
@@ -17,11 +18,16 @@ CREATE TABLE IF NOT EXISTS task_files (
 	size INTEGER NOT NULL,
 	path TEXT NOT NULL,
 	priority INTEGER NOT NULL DEFAULT 1,
+	upload_status TEXT NOT NULL DEFAULT '',
 	FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
 );
 CREATE INDEX IF NOT EXISTS idx_task_files_task_id ON task_files(task_id);
 `
 
+// taskFileBatchSize bounds the task ids bound to one ListByTasks query, well
+// below SQLite's limit on query parameters.
+const taskFileBatchSize = 500
+
 type TaskFileRepository struct {
 	db *sql.DB
 }
@@ -34,6 +40,19 @@ func (r *TaskFileRepository) Init(ctx context.Context) error {
 	if _, err := r.db.ExecContext(ctx, createTaskFilesTable); err != nil {
 		return fmt.Errorf("create task_files table: %w", err)
 	}
+	return r.ensureTaskFileColumns(ctx)
+}
+
+func (r *TaskFileRepository) ensureTaskFileColumns(ctx context.Context) error {
+	columns, err := tableColumns(ctx, r.db, "task_files")
+	if err != nil {
+		return err
+	}
+	if _, exists := columns["upload_status"]; !exists {
+		if _, err := r.db.ExecContext(ctx, `ALTER TABLE task_files ADD COLUMN upload_status TEXT NOT NULL DEFAULT ''`); err != nil {
+			return fmt.Errorf("add column upload_status: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -70,7 +89,7 @@ VALUES (?, ?, ?, ?, ?)`,
 
 func (r *TaskFileRepository) ListByTask(ctx context.Context, taskID int64) ([]domain.TaskFile, error) {
 	rows, err := r.db.QueryContext(ctx, `
-SELECT id, task_id, name, size, path, priority
+SELECT id, task_id, name, size, path, priority, upload_status
 FROM task_files
 WHERE task_id=?
 ORDER BY id ASC`, taskID)
@@ -81,12 +100,130 @@ ORDER BY id ASC`, taskID)
 
 	var files []domain.TaskFile
 	for rows.Next() {
-		var file domain.TaskFile
-		if err := rows.Scan(&file.ID, &file.TaskID, &file.Name, &file.Size, &file.Path, &file.Priority); err != nil {
-			return nil, fmt.Errorf("scan file: %w", err)
+		file, err := scanTaskFile(rows)
+		if err != nil {
+			return nil, err
 		}
 		files = append(files, file)
 	}
 
 	return files, rows.Err()
 }
+
+// ListByTasks loads the files of taskIDs with one query per
+// taskFileBatchSize ids. Tasks without files have no entry in the result.
+func (r *TaskFileRepository) ListByTasks(ctx context.Context, taskIDs []int64) (map[int64][]domain.TaskFile, error) {
+	result := make(map[int64][]domain.TaskFile, len(taskIDs))
+	for start := 0; start < len(taskIDs); start += taskFileBatchSize {
+		batch := taskIDs[start:min(start+taskFileBatchSize, len(taskIDs))]
+		placeholders := make([]string, len(batch))
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			placeholders[i] = "?"
+			args[i] = id
+		}
+
+		rows, err := r.db.QueryContext(ctx, `
+SELECT id, task_id, name, size, path, priority, upload_status
+FROM task_files
+WHERE task_id IN (`+strings.Join(placeholders, ",")+`)
+ORDER BY task_id ASC, id ASC`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("query task files: %w", err)
+		}
+		for rows.Next() {
+			file, err := scanTaskFile(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			result[file.TaskID] = append(result[file.TaskID], file)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func scanTaskFile(rows *sql.Rows) (domain.TaskFile, error) {
+	var (
+		file   domain.TaskFile
+		status string
+	)
+	if err := rows.Scan(&file.ID, &file.TaskID, &file.Name, &file.Size, &file.Path, &file.Priority, &status); err != nil {
+		return file, fmt.Errorf("scan file: %w", err)
+	}
+	file.UploadStatus = domain.FileUploadStatus(status)
+	return file, nil
+}
+
+func (r *TaskFileRepository) UpdatePriorities(ctx context.Context, taskID int64, priorities map[int64]int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	for fileID, priority := range priorities {
+		res, err := tx.ExecContext(ctx, `
+UPDATE task_files
+SET priority=?
+WHERE id=? AND task_id=?`,
+			priority,
+			fileID,
+			taskID,
+		)
+		if err != nil {
+			return fmt.Errorf("update file priority: %w", err)
+		}
+		aff, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("file priority rows affected: %w", err)
+		}
+		if aff == 0 {
+			return fmt.Errorf("task file %d not found", fileID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit tx: %w", err)
+	}
+	return nil
+}
+
+// ResetUploadStatus marks the selected files of a task as waiting for upload
+// and clears the status of skipped ones.
+func (r *TaskFileRepository) ResetUploadStatus(ctx context.Context, taskID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE task_files
+SET upload_status = CASE WHEN priority = ? THEN '' ELSE ? END
+WHERE task_id=?`,
+		domain.FilePrioritySkip,
+		string(domain.FileUploadPending),
+		taskID,
+	)
+	if err != nil {
+		return fmt.Errorf("reset upload status: %w", err)
+	}
+	return nil
+}
+
+// UpdateUploadStatus sets the status of the file uploaded under name, the
+// file's path relative to the task directory.
+func (r *TaskFileRepository) UpdateUploadStatus(ctx context.Context, taskID int64, name string, status domain.FileUploadStatus) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE task_files
+SET upload_status=?
+WHERE task_id=? AND name=?`,
+		string(status),
+		taskID,
+		name,
+	)
+	if err != nil {
+		return fmt.Errorf("update upload status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/repository"
+)
+
+const (
+	benchTasks        = 1000
+	benchFilesPerTask = 5
+)
+
+// newTaskFileRepos opens an empty database and returns its task and task
+// file repositories.
+func newTaskFileRepos(tb testing.TB) (repository.TaskRepository, *TaskFileRepository) {
+	tb.Helper()
+	ctx := context.Background()
+
+	db, err := Open(filepath.Join(tb.TempDir(), "test.db"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+
+	tasks := NewTaskRepository(db)
+	if err := tasks.Init(ctx); err != nil {
+		tb.Fatal(err)
+	}
+	files := &TaskFileRepository{db: db}
+	if err := files.Init(ctx); err != nil {
+		tb.Fatal(err)
+	}
+	return tasks, files
+}
+
+// createTaskWithFiles adds a task of user 1 with filesPerTask files and
+// returns its id. n keeps the info-hash unique.
+func createTaskWithFiles(tb testing.TB, tasks repository.TaskRepository, files *TaskFileRepository, n, filesPerTask int) int64 {
+	tb.Helper()
+	ctx := context.Background()
+
+	id, err := tasks.Create(ctx, &domain.Task{
+		UserID:    1,
+		MagnetURI: fmt.Sprintf("magnet:?xt=urn:btih:%040x", n),
+		InfoHash:  fmt.Sprintf("%040x", n),
+		Status:    domain.TaskStatusCompleted,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	taskFiles := make([]domain.TaskFile, filesPerTask)
+	for j := range taskFiles {
+		name := fmt.Sprintf("episode-%02d.mkv", j)
+		taskFiles[j] = domain.TaskFile{Name: name, Size: 1 << 30, Path: name, Priority: domain.FilePriorityNormal}
+	}
+	if err := files.ReplaceForTask(ctx, id, taskFiles); err != nil {
+		tb.Fatal(err)
+	}
+	return id
+}
+
+// seedTaskFiles creates a database with benchTasks tasks of benchFilesPerTask
+// files each and returns the file repository and the task ids.
+func seedTaskFiles(b *testing.B) (*TaskFileRepository, []int64) {
+	b.Helper()
+	tasks, files := newTaskFileRepos(b)
+	ids := make([]int64, benchTasks)
+	for i := range ids {
+		ids[i] = createTaskWithFiles(b, tasks, files, i, benchFilesPerTask)
+	}
+	return files, ids
+}
+
+func TestListByTasks(t *testing.T) {
+	ctx := context.Background()
+	tasks, files := newTaskFileRepos(t)
+	three := createTaskWithFiles(t, tasks, files, 1, 3)
+	none := createTaskWithFiles(t, tasks, files, 2, 0)
+	one := createTaskWithFiles(t, tasks, files, 3, 1)
+	unlisted := createTaskWithFiles(t, tasks, files, 4, 2)
+
+	result, err := files.ListByTasks(ctx, []int64{one, three, none, 999})
+	if err != nil {
+		t.Fatalf("ListByTasks: %v", err)
+	}
+	if len(result) != 2 {
+		t.Errorf("got files for %d tasks, want 2: %v", len(result), result)
+	}
+	for id, want := range map[int64]int{three: 3, one: 1} {
+		got := result[id]
+		if len(got) != want {
+			t.Errorf("task %d: got %d files, want %d", id, len(got), want)
+			continue
+		}
+		single, err := files.ListByTask(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, file := range got {
+			if file != single[i] {
+				t.Errorf("task %d file %d = %+v, want %+v as from ListByTask", id, i, file, single[i])
+			}
+		}
+	}
+	if got, ok := result[none]; ok {
+		t.Errorf("task without files has an entry: %v", got)
+	}
+	if _, ok := result[unlisted]; ok {
+		t.Error("files of a task that was not asked for were returned")
+	}
+
+	empty, err := files.ListByTasks(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListByTasks(nil): %v", err)
+	}
+	if len(empty) != 0 {
+		t.Errorf("ListByTasks(nil) = %v, want no entries", empty)
+	}
+}
+
+func TestListByTasksSpansBatches(t *testing.T) {
+	tasks, files := newTaskFileRepos(t)
+	ids := make([]int64, taskFileBatchSize+10)
+	for i := range ids {
+		ids[i] = createTaskWithFiles(t, tasks, files, i, 1)
+	}
+
+	result, err := files.ListByTasks(context.Background(), ids)
+	if err != nil {
+		t.Fatalf("ListByTasks: %v", err)
+	}
+	if len(result) != len(ids) {
+		t.Fatalf("got files for %d tasks, want %d", len(result), len(ids))
+	}
+	for _, id := range ids {
+		if len(result[id]) != 1 {
+			t.Errorf("task %d: got %d files, want 1", id, len(result[id]))
+		}
+	}
+}
+
+// BenchmarkListFilesPerTask is the query pattern the task listings used to
+// follow: one query per task.
+func BenchmarkListFilesPerTask(b *testing.B) {
+	files, ids := seedTaskFiles(b)
+	ctx := context.Background()
+
+	for b.Loop() {
+		for _, id := range ids {
+			if _, err := files.ListByTask(ctx, id); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkListFilesBatched loads the same files with ListByTasks.
+func BenchmarkListFilesBatched(b *testing.B) {
+	files, ids := seedTaskFiles(b)
+	ctx := context.Background()
+
+	for b.Loop() {
+		result, err := files.ListByTasks(ctx, ids)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(result) != benchTasks {
+			b.Fatalf("got files for %d tasks, want %d", len(result), benchTasks)
+		}
+	}
+}
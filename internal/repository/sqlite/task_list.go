@@ -0,0 +1,181 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"magnet-player/internal/domain"
+)
+
+// taskSortColumns maps a sort to its column. Ids grow with creation time, so
+// sorting by creation uses the primary key.
+var taskSortColumns = map[domain.TaskSort]string{
+	domain.TaskSortCreated:  "id",
+	domain.TaskSortUpdated:  "updated_at",
+	domain.TaskSortSize:     "total_size",
+	domain.TaskSortProgress: "progress",
+}
+
+// ListPage filters and orders tasks and pages through them with a keyset
+// cursor on the sort column and id, so pages stay stable while tasks are added.
+func (r *TaskRepository) ListPage(ctx context.Context, opts domain.TaskListOptions) (*domain.TaskPage, error) {
+	sort := opts.Sort
+	if sort == "" {
+		sort = domain.TaskSortCreated
+	}
+	column, ok := taskSortColumns[sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown task sort %q", sort)
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+	if opts.UserID > 0 {
+		where = append(where, "user_id=?")
+		args = append(args, opts.UserID)
+	}
+	if len(opts.Statuses) > 0 {
+		placeholders, statusArgs := statusFilter(opts.Statuses)
+		where = append(where, "status IN ("+placeholders+")")
+		args = append(args, statusArgs...)
+	}
+	if name := strings.TrimSpace(opts.Name); name != "" {
+		pattern := "%" + escapeLike(name) + "%"
+		where = append(where, `(torrent_name LIKE ? ESCAPE '\' OR display_name LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	if opts.CreatedFrom != nil {
+		where = append(where, "created_at>=?")
+		args = append(args, opts.CreatedFrom.UTC())
+	}
+	if opts.CreatedTo != nil {
+		where = append(where, "created_at<?")
+		args = append(args, opts.CreatedTo.UTC())
+	}
+
+	filter := ""
+	if len(where) > 0 {
+		filter = "WHERE " + strings.Join(where, " AND ")
+	}
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks `+filter, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count tasks: %w", err)
+	}
+
+	cmp, dir := "<", "DESC"
+	if opts.Ascending {
+		cmp, dir = ">", "ASC"
+	}
+	if opts.Cursor != "" {
+		value, id, err := decodeTaskCursor(opts.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		if column == "id" {
+			where = append(where, "id"+cmp+"?")
+			args = append(args, id)
+		} else {
+			where = append(where, fmt.Sprintf("(%[1]s%[2]s? OR (%[1]s=? AND id%[2]s?))", column, cmp))
+			args = append(args, value, value, id)
+		}
+		filter = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	query := `
+SELECT ` + taskColumns + `
+FROM tasks
+` + filter + `
+ORDER BY ` + column + ` ` + dir
+	if column != "id" {
+		query += `, id ` + dir
+	}
+	if opts.Limit > 0 {
+		// one extra row tells whether another page follows
+		query += ` LIMIT ` + strconv.Itoa(opts.Limit+1)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query task page: %w", err)
+	}
+	defer rows.Close()
+
+	page := &domain.TaskPage{Total: total}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Tasks = append(page.Tasks, *task)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if opts.Limit > 0 && len(page.Tasks) > opts.Limit {
+		page.Tasks = page.Tasks[:opts.Limit]
+		page.NextCursor = encodeTaskCursor(sort, page.Tasks[opts.Limit-1])
+	}
+	return page, nil
+}
+
+// encodeTaskCursor records the sort value and id of the last task of a page.
+func encodeTaskCursor(sort domain.TaskSort, task domain.Task) string {
+	var value string
+	switch sort {
+	case domain.TaskSortUpdated:
+		value = task.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case domain.TaskSortSize:
+		value = strconv.FormatInt(task.TotalSize, 10)
+	case domain.TaskSortProgress:
+		value = strconv.Itoa(task.Progress)
+	}
+	raw := string(sort) + "|" + value + "|" + strconv.FormatInt(task.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeTaskCursor is the inverse of encodeTaskCursor. A cursor only applies
+// to the sort it was created for.
+func decodeTaskCursor(cursor string, sort domain.TaskSort) (any, int64, error) {
+	invalid := fmt.Errorf("invalid cursor")
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, invalid
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 || domain.TaskSort(parts[0]) != sort {
+		return nil, 0, invalid
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, 0, invalid
+	}
+
+	var value any
+	switch sort {
+	case domain.TaskSortUpdated:
+		t, err := time.Parse(time.RFC3339Nano, parts[1])
+		if err != nil {
+			return nil, 0, invalid
+		}
+		value = t.UTC()
+	case domain.TaskSortSize, domain.TaskSortProgress:
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, 0, invalid
+		}
+		value = n
+	}
+	return value, id, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -15,6 +15,7 @@ const (
 	createTasksTable = `
 CREATE TABLE IF NOT EXISTS tasks (
 	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL DEFAULT 0,
 	magnet_uri TEXT NOT NULL,
 	status TEXT NOT NULL,
 	progress INTEGER NOT NULL DEFAULT 0,
@@ -33,9 +34,30 @@ CREATE TABLE IF NOT EXISTS tasks (
 	created_at DATETIME NOT NULL,
 	updated_at DATETIME NOT NULL,
 	downloaded_at DATETIME NULL,
-	uploaded_at DATETIME NULL
+	uploaded_at DATETIME NULL,
+	metainfo BLOB NULL,
+	download_limit INTEGER NOT NULL DEFAULT 0,
+	upload_limit INTEGER NOT NULL DEFAULT 0,
+	seed_mode TEXT NOT NULL DEFAULT '',
+	seed_ratio REAL NOT NULL DEFAULT 0,
+	seed_seconds INTEGER NOT NULL DEFAULT 0,
+	peer_uploaded INTEGER NOT NULL DEFAULT 0,
+	seeding_started_at DATETIME NULL,
+	upload_done_bytes INTEGER NOT NULL DEFAULT 0,
+	upload_total_bytes INTEGER NOT NULL DEFAULT 0,
+	upload_speed INTEGER NOT NULL DEFAULT 0,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_retry_at DATETIME NULL,
+	queue_priority INTEGER NOT NULL DEFAULT 0,
+	queue_position INTEGER NOT NULL DEFAULT 0,
+	info_hash TEXT NULL,
+	display_name TEXT NOT NULL DEFAULT '',
+	expected_size INTEGER NOT NULL DEFAULT 0
 );
 `
+
+	// taskColumns lists the columns read by scanTask, in order.
+	taskColumns = `id, user_id, magnet_uri, status, progress, speed, downloaded_bytes, total_size, total_peers, active_peers, pending_peers, connected_seeders, half_open_peers, torrent_name, local_path, s3_location, error_message, created_at, updated_at, downloaded_at, uploaded_at, download_limit, upload_limit, seed_mode, seed_ratio, seed_seconds, peer_uploaded, seeding_started_at, upload_done_bytes, upload_total_bytes, upload_speed, attempts, next_retry_at, queue_priority, queue_position, info_hash, display_name, expected_size`
 )
 
 type TaskRepository struct {
@@ -57,29 +79,9 @@ func (r *TaskRepository) Init(ctx context.Context) error {
 }
 
 func (r *TaskRepository) ensureTaskColumns(ctx context.Context) error {
-	rows, err := r.db.QueryContext(ctx, `PRAGMA table_info(tasks)`)
+	columns, err := tableColumns(ctx, r.db, "tasks")
 	if err != nil {
-		return fmt.Errorf("describe tasks table: %w", err)
-	}
-	defer rows.Close()
-
-	columns := map[string]struct{}{}
-	for rows.Next() {
-		var (
-			cid       int
-			name      string
-			ctype     string
-			notnull   int
-			dfltValue any
-			pk        int
-		)
-		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err != nil {
-			return fmt.Errorf("scan pragma table info: %w", err)
-		}
-		columns[name] = struct{}{}
-	}
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("iterate pragma table info: %w", err)
+		return err
 	}
 
 	addColumn := func(name, statement string) error {
@@ -107,6 +109,133 @@ func (r *TaskRepository) ensureTaskColumns(ctx context.Context) error {
 	if err := addColumn("half_open_peers", `ALTER TABLE tasks ADD COLUMN half_open_peers INTEGER NOT NULL DEFAULT 0`); err != nil {
 		return err
 	}
+	if err := addColumn("metainfo", `ALTER TABLE tasks ADD COLUMN metainfo BLOB NULL`); err != nil {
+		return err
+	}
+	if err := addColumn("user_id", `ALTER TABLE tasks ADD COLUMN user_id INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := addColumn("download_limit", `ALTER TABLE tasks ADD COLUMN download_limit INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := addColumn("upload_limit", `ALTER TABLE tasks ADD COLUMN upload_limit INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := addColumn("seed_mode", `ALTER TABLE tasks ADD COLUMN seed_mode TEXT NOT NULL DEFAULT ''`); err != nil {
+		return err
+	}
+	if err := addColumn("seed_ratio", `ALTER TABLE tasks ADD COLUMN seed_ratio REAL NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := addColumn("seed_seconds", `ALTER TABLE tasks ADD COLUMN seed_seconds INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := addColumn("peer_uploaded", `ALTER TABLE tasks ADD COLUMN peer_uploaded INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := addColumn("seeding_started_at", `ALTER TABLE tasks ADD COLUMN seeding_started_at DATETIME NULL`); err != nil {
+		return err
+	}
+	if err := addColumn("upload_done_bytes", `ALTER TABLE tasks ADD COLUMN upload_done_bytes INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := addColumn("upload_total_bytes", `ALTER TABLE tasks ADD COLUMN upload_total_bytes INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := addColumn("upload_speed", `ALTER TABLE tasks ADD COLUMN upload_speed INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := addColumn("attempts", `ALTER TABLE tasks ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := addColumn("next_retry_at", `ALTER TABLE tasks ADD COLUMN next_retry_at DATETIME NULL`); err != nil {
+		return err
+	}
+	if err := addColumn("queue_priority", `ALTER TABLE tasks ADD COLUMN queue_priority INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	if err := addColumn("queue_position", `ALTER TABLE tasks ADD COLUMN queue_position INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	// tasks created before queue positions existed keep their creation order
+	if _, err := r.db.ExecContext(ctx, `UPDATE tasks SET queue_position=id WHERE queue_position=0`); err != nil {
+		return fmt.Errorf("backfill queue positions: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_tasks_user_id ON tasks(user_id)`); err != nil {
+		return fmt.Errorf("create tasks user index: %w", err)
+	}
+	if err := addColumn("info_hash", `ALTER TABLE tasks ADD COLUMN info_hash TEXT NULL`); err != nil {
+		return err
+	}
+	// info-hashes used to be unique across all users
+	if _, err := r.db.ExecContext(ctx, `DROP INDEX IF EXISTS idx_tasks_info_hash`); err != nil {
+		return fmt.Errorf("drop tasks info hash index: %w", err)
+	}
+	if err := r.backfillInfoHashes(ctx); err != nil {
+		return err
+	}
+	if _, err := r.db.ExecContext(ctx, `CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_user_info_hash ON tasks(user_id, info_hash)`); err != nil {
+		return fmt.Errorf("create tasks info hash index: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS idx_tasks_queue ON tasks(queue_priority, queue_position)`); err != nil {
+		return fmt.Errorf("create tasks queue index: %w", err)
+	}
+	if err := addColumn("display_name", `ALTER TABLE tasks ADD COLUMN display_name TEXT NOT NULL DEFAULT ''`); err != nil {
+		return err
+	}
+	if err := addColumn("expected_size", `ALTER TABLE tasks ADD COLUMN expected_size INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+	return nil
+}
+
+// backfillInfoHashes derives the info-hash of tasks created before it was
+// stored. Only the oldest of a user's tasks for one torrent gets it, so the
+// unique index can be created over existing duplicates.
+func (r *TaskRepository) backfillInfoHashes(ctx context.Context) error {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, user_id, magnet_uri FROM tasks WHERE info_hash IS NULL ORDER BY id ASC`)
+	if err != nil {
+		return fmt.Errorf("query tasks without info hash: %w", err)
+	}
+	type taskHash struct {
+		id     int64
+		userID int64
+		hash   string
+	}
+	var hashes []taskHash
+	for rows.Next() {
+		var (
+			id     int64
+			userID int64
+			magnet string
+		)
+		if err := rows.Scan(&id, &userID, &magnet); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan task magnet: %w", err)
+		}
+		if hash, err := domain.MagnetInfoHash(magnet); err == nil {
+			hashes = append(hashes, taskHash{id: id, userID: userID, hash: hash})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query tasks without info hash: %w", err)
+	}
+
+	for _, th := range hashes {
+		_, err := r.db.ExecContext(ctx, `
+UPDATE tasks
+SET info_hash=?
+WHERE id=? AND NOT EXISTS (SELECT 1 FROM tasks WHERE user_id=? AND info_hash=?)`,
+			th.hash,
+			th.id,
+			th.userID,
+			th.hash,
+		)
+		if err != nil {
+			return fmt.Errorf("backfill info hash: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -116,9 +245,11 @@ func (r *TaskRepository) Create(ctx context.Context, task *domain.Task) (int64,
 	task.UpdatedAt = now
 
 	res, err := r.db.ExecContext(ctx, `
-INSERT INTO tasks (magnet_uri, status, progress, speed, downloaded_bytes, total_size, total_peers, active_peers, pending_peers, connected_seeders, half_open_peers, torrent_name, local_path, s3_location, error_message, created_at, updated_at)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+INSERT INTO tasks (user_id, magnet_uri, info_hash, status, progress, speed, downloaded_bytes, total_size, total_peers, active_peers, pending_peers, connected_seeders, half_open_peers, torrent_name, local_path, s3_location, error_message, created_at, updated_at, download_limit, upload_limit, display_name, expected_size, queue_priority, queue_position)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT COALESCE(MAX(queue_position), 0) + 1 FROM tasks))`,
+		task.UserID,
 		task.MagnetURI,
+		nullString(task.InfoHash),
 		string(task.Status),
 		task.Progress,
 		task.Speed,
@@ -135,8 +266,16 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		task.ErrorMessage,
 		task.CreatedAt,
 		task.UpdatedAt,
+		task.DownloadLimit,
+		task.UploadLimit,
+		task.DisplayName,
+		task.ExpectedSize,
+		task.QueuePriority,
 	)
 	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "unique") {
+			return 0, fmt.Errorf("task already exists: %w", err)
+		}
 		return 0, fmt.Errorf("insert task: %w", err)
 	}
 
@@ -145,16 +284,22 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		return 0, fmt.Errorf("get last insert id: %w", err)
 	}
 	task.ID = id
+	if err := r.db.QueryRowContext(ctx, `SELECT queue_position FROM tasks WHERE id=?`, id).Scan(&task.QueuePosition); err != nil {
+		return 0, fmt.Errorf("get queue position: %w", err)
+	}
 	return id, nil
 }
 
 func (r *TaskRepository) Update(ctx context.Context, task *domain.Task) error {
 	task.UpdatedAt = time.Now().UTC()
+	seedMode, seedRatio, seedSeconds := seedPolicyColumns(task.SeedPolicy)
 	_, err := r.db.ExecContext(ctx, `
 UPDATE tasks
-SET magnet_uri=?, status=?, progress=?, speed=?, downloaded_bytes=?, total_size=?, total_peers=?, active_peers=?, pending_peers=?, connected_seeders=?, half_open_peers=?, torrent_name=?, local_path=?, s3_location=?, error_message=?, created_at=?, updated_at=?, downloaded_at=?, uploaded_at=?
+SET user_id=?, magnet_uri=?, info_hash=?, status=?, progress=?, speed=?, downloaded_bytes=?, total_size=?, total_peers=?, active_peers=?, pending_peers=?, connected_seeders=?, half_open_peers=?, torrent_name=?, local_path=?, s3_location=?, error_message=?, created_at=?, updated_at=?, downloaded_at=?, uploaded_at=?, download_limit=?, upload_limit=?, seed_mode=?, seed_ratio=?, seed_seconds=?, peer_uploaded=?, seeding_started_at=?, upload_done_bytes=?, upload_total_bytes=?, upload_speed=?, attempts=?, next_retry_at=?, queue_priority=?, queue_position=?, display_name=?, expected_size=?
 WHERE id=?`,
+		task.UserID,
 		task.MagnetURI,
+		nullString(task.InfoHash),
 		string(task.Status),
 		task.Progress,
 		task.Speed,
@@ -173,6 +318,22 @@ WHERE id=?`,
 		task.UpdatedAt,
 		nullTime(task.DownloadedAt),
 		nullTime(task.UploadedAt),
+		task.DownloadLimit,
+		task.UploadLimit,
+		seedMode,
+		seedRatio,
+		seedSeconds,
+		task.PeerUploaded,
+		nullTime(task.SeedingStartedAt),
+		task.UploadDoneBytes,
+		task.UploadTotalBytes,
+		task.UploadSpeed,
+		task.Attempts,
+		nullTime(task.NextRetryAt),
+		task.QueuePriority,
+		task.QueuePosition,
+		task.DisplayName,
+		task.ExpectedSize,
 		task.ID,
 	)
 	if err != nil {
@@ -276,6 +437,318 @@ WHERE id=?`,
 	return nil
 }
 
+func (r *TaskRepository) UpdateRateLimits(ctx context.Context, id int64, downloadLimit, uploadLimit int64) error {
+	res, err := r.db.ExecContext(ctx, `
+UPDATE tasks
+SET download_limit=?, upload_limit=?, updated_at=?
+WHERE id=?`,
+		downloadLimit,
+		uploadLimit,
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("update task rate limits: %w", err)
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("task rate limits rows affected: %w", err)
+	}
+	if aff == 0 {
+		return fmt.Errorf("task not found")
+	}
+	return nil
+}
+
+// UpdateSeedPolicy stores a per-task seeding policy; nil restores the default.
+func (r *TaskRepository) UpdateSeedPolicy(ctx context.Context, id int64, policy *domain.SeedingPolicy) error {
+	mode, ratio, seconds := seedPolicyColumns(policy)
+	res, err := r.db.ExecContext(ctx, `
+UPDATE tasks
+SET seed_mode=?, seed_ratio=?, seed_seconds=?, updated_at=?
+WHERE id=?`,
+		mode,
+		ratio,
+		seconds,
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("update seed policy: %w", err)
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("seed policy rows affected: %w", err)
+	}
+	if aff == 0 {
+		return fmt.Errorf("task not found")
+	}
+	return nil
+}
+
+func (r *TaskRepository) UpdatePeerUploaded(ctx context.Context, id int64, uploaded int64) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE tasks
+SET peer_uploaded=?, updated_at=?
+WHERE id=?`,
+		uploaded,
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("update peer uploaded: %w", err)
+	}
+	return nil
+}
+
+// MarkSeeding switches the task to seeding, keeping the original start time
+// when seeding resumes after a restart.
+func (r *TaskRepository) MarkSeeding(ctx context.Context, id int64, startedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE tasks
+SET status=?, seeding_started_at=COALESCE(seeding_started_at, ?), updated_at=?
+WHERE id=?`,
+		string(domain.TaskStatusSeeding),
+		startedAt.UTC(),
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("mark seeding: %w", err)
+	}
+	return nil
+}
+
+func (r *TaskRepository) UpdateUploadProgress(ctx context.Context, id int64, done, total, speed int64) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE tasks
+SET upload_done_bytes=?, upload_total_bytes=?, upload_speed=?, updated_at=?
+WHERE id=?`,
+		done,
+		total,
+		speed,
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("update upload progress: %w", err)
+	}
+	return nil
+}
+
+// UpdateRetry records the failure count and the time of the next automatic
+// retry; a nil nextRetryAt means none is scheduled.
+func (r *TaskRepository) UpdateRetry(ctx context.Context, id int64, attempts int, nextRetryAt *time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE tasks
+SET attempts=?, next_retry_at=?, updated_at=?
+WHERE id=?`,
+		attempts,
+		nullTime(nextRetryAt),
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("update task retry: %w", err)
+	}
+	return nil
+}
+
+func (r *TaskRepository) UpdateQueuePriority(ctx context.Context, id int64, priority int) error {
+	res, err := r.db.ExecContext(ctx, `
+UPDATE tasks
+SET queue_priority=?, updated_at=?
+WHERE id=?`,
+		priority,
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("update queue priority: %w", err)
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("queue priority rows affected: %w", err)
+	}
+	if aff == 0 {
+		return fmt.Errorf("task not found")
+	}
+	return nil
+}
+
+// MoveInQueue reorders a queued task within its owner's queue at the same
+// priority. Up and down swap positions with the neighbouring task; top and
+// bottom rotate the owner's positions in between, so other users' tasks keep
+// theirs and positions stay unique.
+func (r *TaskRepository) MoveInQueue(ctx context.Context, userID, id int64, move domain.QueueMove) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		priority int
+		position int64
+	)
+	if err := tx.QueryRowContext(ctx, `SELECT queue_priority, queue_position FROM tasks WHERE id=? AND user_id=?`, id, userID).Scan(&priority, &position); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("task not found")
+		}
+		return fmt.Errorf("get queue position: %w", err)
+	}
+
+	placeholders, statusArgs := statusFilter(domain.QueuedStatuses)
+	queued := func(query string, args ...any) *sql.Row {
+		return tx.QueryRowContext(ctx, fmt.Sprintf(query, placeholders), append(args, statusArgs...)...)
+	}
+	now := time.Now().UTC()
+	setPosition := func(taskID, pos int64) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE tasks SET queue_position=?, updated_at=? WHERE id=?`, pos, now, taskID); err != nil {
+			return fmt.Errorf("update queue position: %w", err)
+		}
+		return nil
+	}
+
+	switch move {
+	case domain.QueueMoveUp, domain.QueueMoveDown:
+		query := `SELECT id, queue_position FROM tasks WHERE user_id=? AND queue_priority=? AND queue_position<? AND status IN (%s) ORDER BY queue_position DESC LIMIT 1`
+		if move == domain.QueueMoveDown {
+			query = `SELECT id, queue_position FROM tasks WHERE user_id=? AND queue_priority=? AND queue_position>? AND status IN (%s) ORDER BY queue_position ASC LIMIT 1`
+		}
+		var (
+			neighbour    int64
+			neighbourPos int64
+		)
+		if err := queued(query, userID, priority, position).Scan(&neighbour, &neighbourPos); err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+			return fmt.Errorf("find queue neighbour: %w", err)
+		}
+		if err := setPosition(neighbour, position); err != nil {
+			return err
+		}
+		if err := setPosition(id, neighbourPos); err != nil {
+			return err
+		}
+	case domain.QueueMoveTop, domain.QueueMoveBottom:
+		query := `SELECT MIN(queue_position) FROM tasks WHERE user_id=? AND queue_priority=? AND status IN (%s)`
+		if move == domain.QueueMoveBottom {
+			query = `SELECT MAX(queue_position) FROM tasks WHERE user_id=? AND queue_priority=? AND status IN (%s)`
+		}
+		var target sql.NullInt64
+		if err := queued(query, userID, priority).Scan(&target); err != nil {
+			return fmt.Errorf("find queue end: %w", err)
+		}
+		if !target.Valid || target.Int64 == position {
+			return nil
+		}
+
+		low, high := min(target.Int64, position), max(target.Int64, position)
+		args := append([]any{userID, priority, low, high}, statusArgs...)
+		rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
+SELECT id, queue_position FROM tasks
+WHERE user_id=? AND queue_priority=? AND queue_position BETWEEN ? AND ? AND status IN (%s)
+ORDER BY queue_position ASC`, placeholders), args...)
+		if err != nil {
+			return fmt.Errorf("list queue range: %w", err)
+		}
+		var (
+			others    []int64
+			positions []int64
+		)
+		for rows.Next() {
+			var taskID, pos int64
+			if err := rows.Scan(&taskID, &pos); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan queue range: %w", err)
+			}
+			positions = append(positions, pos)
+			if taskID != id {
+				others = append(others, taskID)
+			}
+		}
+		if err := rows.Close(); err != nil {
+			return fmt.Errorf("list queue range: %w", err)
+		}
+
+		// hand the same positions out again with the task first or last
+		order := append([]int64{id}, others...)
+		if move == domain.QueueMoveBottom {
+			order = append(others, id)
+		}
+		for i, taskID := range order {
+			if err := setPosition(taskID, positions[i]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown queue move %q", move)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit queue move: %w", err)
+	}
+	return nil
+}
+
+func (r *TaskRepository) UpdateMagnetURI(ctx context.Context, id int64, magnetURI string) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE tasks
+SET magnet_uri=?, updated_at=?
+WHERE id=?`,
+		magnetURI,
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("update magnet uri: %w", err)
+	}
+	return nil
+}
+
+func (r *TaskRepository) AdoptOrphans(ctx context.Context, userID int64) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+UPDATE tasks
+SET user_id=?
+WHERE user_id=0
+  AND NOT EXISTS (SELECT 1 FROM tasks owned WHERE owned.user_id=? AND owned.info_hash=tasks.info_hash)`,
+		userID,
+		userID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("adopt orphaned tasks: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (r *TaskRepository) SetMetainfo(ctx context.Context, id int64, data []byte) error {
+	_, err := r.db.ExecContext(ctx, `
+UPDATE tasks
+SET metainfo=?, updated_at=?
+WHERE id=?`,
+		data,
+		time.Now().UTC(),
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("set task metainfo: %w", err)
+	}
+	return nil
+}
+
+func (r *TaskRepository) GetMetainfo(ctx context.Context, id int64) ([]byte, error) {
+	var data []byte
+	if err := r.db.QueryRowContext(ctx, `SELECT metainfo FROM tasks WHERE id=?`, id).Scan(&data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("task not found")
+		}
+		return nil, fmt.Errorf("get task metainfo: %w", err)
+	}
+	return data, nil
+}
+
 func (r *TaskRepository) Delete(ctx context.Context, id int64) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -305,9 +778,39 @@ func (r *TaskRepository) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
+func (r *TaskRepository) DeleteMany(ctx context.Context, ids []int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM task_files WHERE task_id=?`, id); err != nil {
+			return fmt.Errorf("delete task files: %w", err)
+		}
+		res, err := tx.ExecContext(ctx, `DELETE FROM tasks WHERE id=?`, id)
+		if err != nil {
+			return fmt.Errorf("delete task: %w", err)
+		}
+		aff, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("task delete rows affected: %w", err)
+		}
+		if aff == 0 {
+			return fmt.Errorf("task %d not found", id)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit task delete: %w", err)
+	}
+	return nil
+}
+
 func (r *TaskRepository) Get(ctx context.Context, id int64) (*domain.Task, error) {
 	row := r.db.QueryRowContext(ctx, `
-SELECT id, magnet_uri, status, progress, speed, downloaded_bytes, total_size, total_peers, active_peers, pending_peers, connected_seeders, half_open_peers, torrent_name, local_path, s3_location, error_message, created_at, updated_at, downloaded_at, uploaded_at
+SELECT `+taskColumns+`
 FROM tasks
 WHERE id=?`,
 		id,
@@ -321,9 +824,28 @@ WHERE id=?`,
 	return task, nil
 }
 
+func (r *TaskRepository) GetByInfoHash(ctx context.Context, userID int64, infoHash string) (*domain.Task, error) {
+	row := r.db.QueryRowContext(ctx, `
+SELECT `+taskColumns+`
+FROM tasks
+WHERE user_id=? AND info_hash=?`,
+		userID,
+		infoHash,
+	)
+	return scanTask(row)
+}
+
+func (r *TaskRepository) CountByInfoHash(ctx context.Context, infoHash string) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE info_hash=?`, infoHash).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count tasks by info hash: %w", err)
+	}
+	return count, nil
+}
+
 func (r *TaskRepository) List(ctx context.Context) ([]domain.Task, error) {
 	rows, err := r.db.QueryContext(ctx, `
-SELECT id, magnet_uri, status, progress, speed, downloaded_bytes, total_size, total_peers, active_peers, pending_peers, connected_seeders, half_open_peers, torrent_name, local_path, s3_location, error_message, created_at, updated_at, downloaded_at, uploaded_at
+SELECT `+taskColumns+`
 FROM tasks
 ORDER BY id DESC`)
 	if err != nil {
@@ -343,23 +865,40 @@ ORDER BY id DESC`)
 	return tasks, rows.Err()
 }
 
+func (r *TaskRepository) ListByUser(ctx context.Context, userID int64) ([]domain.Task, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT `+taskColumns+`
+FROM tasks
+WHERE user_id=?
+ORDER BY id DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query user tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []domain.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+
+	return tasks, rows.Err()
+}
+
 func (r *TaskRepository) ListByStatuses(ctx context.Context, statuses ...domain.TaskStatus) ([]domain.Task, error) {
 	if len(statuses) == 0 {
 		return []domain.Task{}, nil
 	}
 
-	placeholders := make([]string, len(statuses))
-	args := make([]interface{}, len(statuses))
-	for i, status := range statuses {
-		placeholders[i] = "?"
-		args[i] = string(status)
-	}
-
+	placeholders, args := statusFilter(statuses)
 	query := fmt.Sprintf(`
-SELECT id, magnet_uri, status, progress, speed, downloaded_bytes, total_size, total_peers, active_peers, pending_peers, connected_seeders, half_open_peers, torrent_name, local_path, s3_location, error_message, created_at, updated_at, downloaded_at, uploaded_at
+SELECT `+taskColumns+`
 FROM tasks
 WHERE status IN (%s)
-ORDER BY id ASC`, strings.Join(placeholders, ","))
+ORDER BY id ASC`, placeholders)
 
 	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -379,6 +918,87 @@ ORDER BY id ASC`, strings.Join(placeholders, ","))
 	return tasks, rows.Err()
 }
 
+func (r *TaskRepository) ListByUserStatuses(ctx context.Context, userID int64, statuses ...domain.TaskStatus) ([]domain.Task, error) {
+	if len(statuses) == 0 {
+		return nil, nil
+	}
+	placeholders, args := statusFilter(statuses)
+	query := fmt.Sprintf(`
+SELECT `+taskColumns+`
+FROM tasks
+WHERE user_id=? AND status IN (%s)
+ORDER BY id DESC`, placeholders)
+
+	rows, err := r.db.QueryContext(ctx, query, append([]any{userID}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("query user tasks by status: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []domain.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+
+	return tasks, rows.Err()
+}
+
+func (r *TaskRepository) ListQueue(ctx context.Context, userID int64) ([]domain.Task, error) {
+	placeholders, args := statusFilter(domain.QueuedStatuses)
+	query := fmt.Sprintf(`
+SELECT `+taskColumns+`
+FROM tasks
+WHERE user_id=? AND status IN (%s)
+ORDER BY queue_priority DESC, queue_position ASC`, placeholders)
+
+	rows, err := r.db.QueryContext(ctx, query, append([]any{userID}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("query task queue: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []domain.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+
+	return tasks, rows.Err()
+}
+
+func (r *TaskRepository) ListDueRetries(ctx context.Context, now time.Time) ([]domain.Task, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT `+taskColumns+`
+FROM tasks
+WHERE status=? AND next_retry_at IS NOT NULL AND next_retry_at <= ?
+ORDER BY next_retry_at ASC`,
+		string(domain.TaskStatusFailed),
+		now.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query due retries: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []domain.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+
+	return tasks, rows.Err()
+}
+
 func scanTask(scanner interface {
 	Scan(dest ...any) error
 }) (*domain.Task, error) {
@@ -389,10 +1009,17 @@ func scanTask(scanner interface {
 		updatedAt         time.Time
 		downloadedAtValid sql.NullTime
 		uploadedAtValid   sql.NullTime
+		seedMode          string
+		seedRatio         float64
+		seedSeconds       int64
+		seedingStarted    sql.NullTime
+		nextRetryAt       sql.NullTime
+		infoHash          sql.NullString
 	)
 
 	if err := scanner.Scan(
 		&task.ID,
+		&task.UserID,
 		&task.MagnetURI,
 		&status,
 		&task.Progress,
@@ -412,6 +1039,23 @@ func scanTask(scanner interface {
 		&updatedAt,
 		&downloadedAtValid,
 		&uploadedAtValid,
+		&task.DownloadLimit,
+		&task.UploadLimit,
+		&seedMode,
+		&seedRatio,
+		&seedSeconds,
+		&task.PeerUploaded,
+		&seedingStarted,
+		&task.UploadDoneBytes,
+		&task.UploadTotalBytes,
+		&task.UploadSpeed,
+		&task.Attempts,
+		&nextRetryAt,
+		&task.QueuePriority,
+		&task.QueuePosition,
+		&infoHash,
+		&task.DisplayName,
+		&task.ExpectedSize,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("task not found")
@@ -420,6 +1064,7 @@ func scanTask(scanner interface {
 	}
 
 	task.Status = domain.TaskStatus(status)
+	task.InfoHash = infoHash.String
 	task.CreatedAt = createdAt.Local()
 	task.UpdatedAt = updatedAt.Local()
 	if downloadedAtValid.Valid {
@@ -430,10 +1075,50 @@ func scanTask(scanner interface {
 		t := uploadedAtValid.Time.Local()
 		task.UploadedAt = &t
 	}
+	if seedMode != "" {
+		task.SeedPolicy = &domain.SeedingPolicy{
+			Mode:     domain.SeedMode(seedMode),
+			Ratio:    seedRatio,
+			Duration: time.Duration(seedSeconds) * time.Second,
+		}
+	}
+	if seedingStarted.Valid {
+		t := seedingStarted.Time.Local()
+		task.SeedingStartedAt = &t
+	}
+	if nextRetryAt.Valid {
+		t := nextRetryAt.Time.Local()
+		task.NextRetryAt = &t
+	}
 
 	return &task, nil
 }
 
+// statusFilter returns the placeholders and arguments for a status IN clause.
+func statusFilter(statuses []domain.TaskStatus) (string, []any) {
+	placeholders := make([]string, len(statuses))
+	args := make([]any, len(statuses))
+	for i, status := range statuses {
+		placeholders[i] = "?"
+		args[i] = string(status)
+	}
+	return strings.Join(placeholders, ","), args
+}
+
+func seedPolicyColumns(policy *domain.SeedingPolicy) (mode string, ratio float64, seconds int64) {
+	if policy == nil {
+		return "", 0, 0
+	}
+	return string(policy.Mode), policy.Ratio, int64(policy.Duration / time.Second)
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
 func nullTime(t *time.Time) any {
 	if t == nil {
 		return nil
@@ -0,0 +1,149 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/repository"
+)
+
+// createTask adds a pending task of user for the torrent numbered n.
+func createTask(t *testing.T, tasks repository.TaskRepository, userID int64, n int) int64 {
+	t.Helper()
+	id, err := tasks.Create(context.Background(), &domain.Task{
+		UserID:    userID,
+		MagnetURI: fmt.Sprintf("magnet:?xt=urn:btih:%040x", n),
+		InfoHash:  fmt.Sprintf("%040x", n),
+		Status:    domain.TaskStatusPending,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestAdoptOrphans(t *testing.T) {
+	ctx := context.Background()
+	tasks, _ := newTaskFileRepos(t)
+	orphan := createTask(t, tasks, 0, 1)
+	// the admin already has a task for torrent 2, so its orphan stays put
+	clash := createTask(t, tasks, 0, 2)
+	owned := createTask(t, tasks, 1, 2)
+	other := createTask(t, tasks, 2, 3)
+
+	adopted, err := tasks.AdoptOrphans(ctx, 1)
+	if err != nil {
+		t.Fatalf("AdoptOrphans: %v", err)
+	}
+	if adopted != 1 {
+		t.Errorf("adopted %d tasks, want 1", adopted)
+	}
+	for id, want := range map[int64]int64{orphan: 1, clash: 0, owned: 1, other: 2} {
+		task, err := tasks.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if task.UserID != want {
+			t.Errorf("task %d owned by %d, want %d", id, task.UserID, want)
+		}
+	}
+
+	// running it again changes nothing
+	if adopted, err := tasks.AdoptOrphans(ctx, 1); err != nil || adopted != 0 {
+		t.Errorf("second AdoptOrphans = %d, %v; want 0, nil", adopted, err)
+	}
+}
+
+func TestMoveInQueue(t *testing.T) {
+	tests := []struct {
+		name string
+		task int // index into the first user's normal priority tasks
+		move domain.QueueMove
+		want []int
+	}{
+		{"up", 1, domain.QueueMoveUp, []int{1, 0, 2}},
+		{"up from the head", 0, domain.QueueMoveUp, []int{0, 1, 2}},
+		{"down", 1, domain.QueueMoveDown, []int{0, 2, 1}},
+		{"down from the tail", 2, domain.QueueMoveDown, []int{0, 1, 2}},
+		{"top", 2, domain.QueueMoveTop, []int{2, 0, 1}},
+		{"bottom", 0, domain.QueueMoveBottom, []int{1, 2, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tasks, _ := newTaskFileRepos(t)
+			// the two users' tasks interleave in the global queue order
+			var mine, theirs []int64
+			for n := range 3 {
+				mine = append(mine, createTask(t, tasks, 1, 2*n))
+				theirs = append(theirs, createTask(t, tasks, 2, 2*n+1))
+			}
+			high := createTask(t, tasks, 1, 10)
+			if err := tasks.UpdateQueuePriority(ctx, high, domain.QueuePriorityHigh); err != nil {
+				t.Fatal(err)
+			}
+			done := createTask(t, tasks, 1, 11)
+			if err := tasks.UpdateStatus(ctx, done, domain.TaskStatusCompleted, nil); err != nil {
+				t.Fatal(err)
+			}
+			positions := func() map[int64]int64 {
+				got := map[int64]int64{}
+				for _, id := range append(append([]int64{high, done}, mine...), theirs...) {
+					task, err := tasks.Get(ctx, id)
+					if err != nil {
+						t.Fatal(err)
+					}
+					got[id] = task.QueuePosition
+				}
+				return got
+			}
+			before := positions()
+
+			if err := tasks.MoveInQueue(ctx, 1, mine[tt.task], tt.move); err != nil {
+				t.Fatalf("MoveInQueue: %v", err)
+			}
+
+			queue, err := tasks.ListQueue(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int64
+			for _, task := range queue {
+				got = append(got, task.ID)
+			}
+			want := []int64{high}
+			for _, i := range tt.want {
+				want = append(want, mine[i])
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("queue = %v, want %v", got, want)
+			}
+
+			after := positions()
+			for _, id := range append([]int64{high, done}, theirs...) {
+				if after[id] != before[id] {
+					t.Errorf("task %d moved from %d to %d", id, before[id], after[id])
+				}
+			}
+			seen := map[int64]bool{}
+			for id, pos := range after {
+				if seen[pos] {
+					t.Errorf("task %d shares position %d", id, pos)
+				}
+				seen[pos] = true
+			}
+		})
+	}
+
+	t.Run("other user's task", func(t *testing.T) {
+		tasks, _ := newTaskFileRepos(t)
+		id := createTask(t, tasks, 2, 1)
+		if err := tasks.MoveInQueue(context.Background(), 1, id, domain.QueueMoveTop); err == nil {
+			t.Error("MoveInQueue moved another user's task")
+		}
+	})
+}
@@ -0,0 +1,175 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/repository"
+)
+
+const createUploadTables = `
+CREATE TABLE IF NOT EXISTS upload_objects (
+	task_id INTEGER NOT NULL,
+	object_key TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	upload_id TEXT NOT NULL DEFAULT '',
+	part_size INTEGER NOT NULL DEFAULT 0,
+	completed INTEGER NOT NULL DEFAULT 0,
+	etag TEXT NOT NULL DEFAULT '',
+	updated_at DATETIME NOT NULL,
+	PRIMARY KEY(task_id, object_key),
+	FOREIGN KEY(task_id) REFERENCES tasks(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS upload_parts (
+	task_id INTEGER NOT NULL,
+	object_key TEXT NOT NULL,
+	part_number INTEGER NOT NULL,
+	etag TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	PRIMARY KEY(task_id, object_key, part_number),
+	FOREIGN KEY(task_id, object_key) REFERENCES upload_objects(task_id, object_key) ON DELETE CASCADE
+);
+`
+
+type UploadStateRepository struct {
+	db *sql.DB
+}
+
+func NewUploadStateRepository(db *sql.DB) repository.UploadStateRepository {
+	return &UploadStateRepository{db: db}
+}
+
+func (r *UploadStateRepository) Init(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, createUploadTables); err != nil {
+		return fmt.Errorf("create upload tables: %w", err)
+	}
+	return nil
+}
+
+func (r *UploadStateRepository) Get(ctx context.Context, taskID int64, key string) (*domain.UploadObject, error) {
+	obj := domain.UploadObject{TaskID: taskID, Key: key}
+	err := r.db.QueryRowContext(ctx, `
+SELECT size, upload_id, part_size, completed, etag, updated_at
+FROM upload_objects
+WHERE task_id = ? AND object_key = ?`,
+		taskID,
+		key,
+	).Scan(
+		&obj.Size,
+		&obj.UploadID,
+		&obj.PartSize,
+		&obj.Completed,
+		&obj.ETag,
+		&obj.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("upload object not found")
+		}
+		return nil, fmt.Errorf("scan upload object: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+SELECT part_number, etag, size
+FROM upload_parts
+WHERE task_id = ? AND object_key = ?
+ORDER BY part_number ASC`,
+		taskID,
+		key,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query upload parts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var part domain.UploadPart
+		if err := rows.Scan(&part.Number, &part.ETag, &part.Size); err != nil {
+			return nil, fmt.Errorf("scan upload part: %w", err)
+		}
+		obj.Parts = append(obj.Parts, part)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &obj, nil
+}
+
+// Save upserts the object and replaces its recorded parts with obj.Parts.
+func (r *UploadStateRepository) Save(ctx context.Context, obj *domain.UploadObject) error {
+	obj.UpdatedAt = time.Now().UTC()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback() // safe no-op on commit
+
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO upload_objects (task_id, object_key, size, upload_id, part_size, completed, etag, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(task_id, object_key) DO UPDATE SET
+	size = excluded.size,
+	upload_id = excluded.upload_id,
+	part_size = excluded.part_size,
+	completed = excluded.completed,
+	etag = excluded.etag,
+	updated_at = excluded.updated_at`,
+		obj.TaskID,
+		obj.Key,
+		obj.Size,
+		obj.UploadID,
+		obj.PartSize,
+		obj.Completed,
+		obj.ETag,
+		obj.UpdatedAt,
+	); err != nil {
+		return fmt.Errorf("save upload object: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM upload_parts WHERE task_id = ? AND object_key = ?`, obj.TaskID, obj.Key); err != nil {
+		return fmt.Errorf("delete upload parts: %w", err)
+	}
+	for _, part := range obj.Parts {
+		if err := insertUploadPart(ctx, tx, obj.TaskID, obj.Key, part); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit upload object: %w", err)
+	}
+	return nil
+}
+
+func (r *UploadStateRepository) AddPart(ctx context.Context, taskID int64, key string, part domain.UploadPart) error {
+	return insertUploadPart(ctx, r.db, taskID, key, part)
+}
+
+func (r *UploadStateRepository) DeleteByTask(ctx context.Context, taskID int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM upload_objects WHERE task_id = ?`, taskID); err != nil {
+		return fmt.Errorf("delete upload state: %w", err)
+	}
+	return nil
+}
+
+func insertUploadPart(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}, taskID int64, key string, part domain.UploadPart) error {
+	if _, err := db.ExecContext(ctx, `
+INSERT OR REPLACE INTO upload_parts (task_id, object_key, part_number, etag, size)
+VALUES (?, ?, ?, ?, ?)`,
+		taskID,
+		key,
+		part.Number,
+		part.ETag,
+		part.Size,
+	); err != nil {
+		return fmt.Errorf("insert upload part: %w", err)
+	}
+	return nil
+}
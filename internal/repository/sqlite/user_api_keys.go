@@ -0,0 +1,151 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"magnet-player/internal/domain"
+)
+
+const createAPIKeysTable = `
+CREATE TABLE IF NOT EXISTS api_keys (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	key_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL DEFAULT '',
+	last_used_at DATETIME NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+`
+
+func (r *UserRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) (int64, error) {
+	key.CreatedAt = time.Now().UTC()
+
+	res, err := r.db.ExecContext(ctx, `
+INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, created_at)
+VALUES (?, ?, ?, ?, ?, ?)`,
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		joinScopes(key.Scopes),
+		key.CreatedAt,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("insert api key: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("api key last insert id: %w", err)
+	}
+	key.ID = id
+	return id, nil
+}
+
+func (r *UserRepository) ListAPIKeys(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT id, user_id, name, prefix, key_hash, scopes, last_used_at, created_at
+FROM api_keys
+WHERE user_id = ?
+ORDER BY id ASC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *UserRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*domain.APIKey, error) {
+	row := r.db.QueryRowContext(ctx, `
+SELECT id, user_id, name, prefix, key_hash, scopes, last_used_at, created_at
+FROM api_keys
+WHERE key_hash = ?`,
+		keyHash,
+	)
+	return scanAPIKey(row)
+}
+
+func (r *UserRepository) TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, usedAt.UTC(), id); err != nil {
+		return fmt.Errorf("touch api key: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) DeleteAPIKey(ctx context.Context, userID, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete api key: %w", err)
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("api key delete rows affected: %w", err)
+	}
+	if aff == 0 {
+		return fmt.Errorf("api key not found")
+	}
+	return nil
+}
+
+func scanAPIKey(row interface {
+	Scan(dest ...any) error
+}) (*domain.APIKey, error) {
+	var (
+		key        domain.APIKey
+		scopes     string
+		lastUsedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.KeyHash,
+		&scopes,
+		&lastUsedAt,
+		&key.CreatedAt,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("api key not found")
+		}
+		return nil, fmt.Errorf("scan api key: %w", err)
+	}
+	if lastUsedAt.Valid {
+		t := lastUsedAt.Time
+		key.LastUsedAt = &t
+	}
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope != "" {
+			key.Scopes = append(key.Scopes, domain.APIKeyScope(scope))
+		}
+	}
+	return &key, nil
+}
+
+func joinScopes(scopes []domain.APIKeyScope) string {
+	parts := make([]string, len(scopes))
+	for i, scope := range scopes {
+		parts[i] = string(scope)
+	}
+	return strings.Join(parts, ",")
+}
@@ -17,6 +17,8 @@ CREATE TABLE IF NOT EXISTS users (
 	id INTEGER PRIMARY KEY AUTOINCREMENT,
 	username TEXT NOT NULL UNIQUE,
 	password_hash TEXT NOT NULL,
+	role TEXT NOT NULL DEFAULT 'user',
+	disabled INTEGER NOT NULL DEFAULT 0,
 	created_at DATETIME NOT NULL,
 	updated_at DATETIME NOT NULL
 );
@@ -34,6 +36,30 @@ func (r *UserRepository) Init(ctx context.Context) error {
 	if _, err := r.db.ExecContext(ctx, createUsersTable); err != nil {
 		return fmt.Errorf("create users table: %w", err)
 	}
+
+	columns, err := tableColumns(ctx, r.db, "users")
+	if err != nil {
+		return err
+	}
+	addColumn := func(name, statement string) error {
+		if _, exists := columns[name]; exists {
+			return nil
+		}
+		if _, err := r.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("add column %s: %w", name, err)
+		}
+		return nil
+	}
+	if err := addColumn("role", `ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'`); err != nil {
+		return err
+	}
+	if err := addColumn("disabled", `ALTER TABLE users ADD COLUMN disabled INTEGER NOT NULL DEFAULT 0`); err != nil {
+		return err
+	}
+
+	if _, err := r.db.ExecContext(ctx, createAPIKeysTable); err != nil {
+		return fmt.Errorf("create api keys table: %w", err)
+	}
 	return nil
 }
 
@@ -43,10 +69,12 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) (int64,
 	user.UpdatedAt = now
 
 	res, err := r.db.ExecContext(ctx, `
-INSERT INTO users (username, password_hash, created_at, updated_at)
-VALUES (?, ?, ?, ?)`,
+INSERT INTO users (username, password_hash, role, disabled, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)`,
 		user.Username,
 		user.PasswordHash,
+		string(user.Role),
+		user.Disabled,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
@@ -67,7 +95,7 @@ VALUES (?, ?, ?, ?)`,
 
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
 	row := r.db.QueryRowContext(ctx, `
-SELECT id, username, password_hash, created_at, updated_at
+SELECT id, username, password_hash, role, disabled, created_at, updated_at
 FROM users
 WHERE username = ?`,
 		username,
@@ -77,7 +105,7 @@ WHERE username = ?`,
 
 func (r *UserRepository) GetByID(ctx context.Context, id int64) (*domain.User, error) {
 	row := r.db.QueryRowContext(ctx, `
-SELECT id, username, password_hash, created_at, updated_at
+SELECT id, username, password_hash, role, disabled, created_at, updated_at
 FROM users
 WHERE id = ?`,
 		id,
@@ -85,14 +113,117 @@ WHERE id = ?`,
 	return scanUser(row)
 }
 
+func (r *UserRepository) List(ctx context.Context) ([]domain.User, error) {
+	rows, err := r.db.QueryContext(ctx, `
+SELECT id, username, password_hash, role, disabled, created_at, updated_at
+FROM users
+ORDER BY id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []domain.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+func (r *UserRepository) Count(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count users: %w", err)
+	}
+	return count, nil
+}
+
+func (r *UserRepository) CountByRole(ctx context.Context, role domain.UserRole) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE role = ?`, string(role)).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count users by role: %w", err)
+	}
+	return count, nil
+}
+
+func (r *UserRepository) UpdateRole(ctx context.Context, id int64, role domain.UserRole) error {
+	return r.updateUser(ctx, id, `UPDATE users SET role = ?, updated_at = ? WHERE id = ?`, string(role))
+}
+
+func (r *UserRepository) SetDisabled(ctx context.Context, id int64, disabled bool) error {
+	return r.updateUser(ctx, id, `UPDATE users SET disabled = ?, updated_at = ? WHERE id = ?`, disabled)
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	return r.updateUser(ctx, id, `UPDATE users SET password_hash = ?, updated_at = ? WHERE id = ?`, passwordHash)
+}
+
+// Delete removes the user together with their sessions and API keys. They
+// are deleted explicitly rather than left to ON DELETE CASCADE, which only
+// applies on connections that enabled foreign keys.
+func (r *UserRepository) Delete(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, id); err != nil {
+		return fmt.Errorf("delete user sessions: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM api_keys WHERE user_id = ?`, id); err != nil {
+		return fmt.Errorf("delete user api keys: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("user delete rows affected: %w", err)
+	}
+	if aff == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit user delete: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) updateUser(ctx context.Context, id int64, statement string, value any) error {
+	res, err := r.db.ExecContext(ctx, statement, value, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("user update rows affected: %w", err)
+	}
+	if aff == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
 func scanUser(row interface {
 	Scan(dest ...any) error
 }) (*domain.User, error) {
-	var user domain.User
+	var (
+		user domain.User
+		role string
+	)
 	if err := row.Scan(
 		&user.ID,
 		&user.Username,
 		&user.PasswordHash,
+		&role,
+		&user.Disabled,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	); err != nil {
@@ -101,5 +232,6 @@ func scanUser(row interface {
 		}
 		return nil, fmt.Errorf("scan user: %w", err)
 	}
+	user.Role = domain.UserRole(role)
 	return &user, nil
 }
@@ -0,0 +1,55 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"magnet-player/internal/domain"
+)
+
+func TestDeleteUser(t *testing.T) {
+	ctx := context.Background()
+	db, users, sessions, userID := newUserRepos(t)
+	otherID, err := users.Create(ctx, &domain.User{Username: "bob", PasswordHash: "x", Role: domain.UserRoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the sessions and keys must go even without ON DELETE CASCADE
+	if _, err := db.Exec(`PRAGMA foreign_keys = OFF`); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, owner := range map[string]int64{"alice": userID, "bob": otherID} {
+		if err := sessions.Create(ctx, &domain.Session{ID: id, UserID: owner, RefreshTokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := users.CreateAPIKey(ctx, &domain.APIKey{UserID: owner, Name: id, Prefix: id, KeyHash: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := users.Delete(ctx, userID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := users.GetByID(ctx, userID); err == nil {
+		t.Error("user was kept")
+	}
+	if _, err := sessions.Get(ctx, "alice"); err == nil {
+		t.Error("session of the deleted user was kept")
+	}
+	if keys, err := users.ListAPIKeys(ctx, userID); err != nil || len(keys) != 0 {
+		t.Errorf("api keys of the deleted user = %v, %v; want none", keys, err)
+	}
+
+	if _, err := sessions.Get(ctx, "bob"); err != nil {
+		t.Errorf("other user's session: %v", err)
+	}
+	if keys, err := users.ListAPIKeys(ctx, otherID); err != nil || len(keys) != 1 {
+		t.Errorf("other user's api keys = %v, %v; want one", keys, err)
+	}
+
+	if err := users.Delete(ctx, userID); err == nil {
+		t.Error("deleting a missing user succeeded")
+	}
+}
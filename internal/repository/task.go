@@ -17,10 +17,41 @@ type TaskRepository interface {
 	UpdateDownloadInfo(ctx context.Context, id int64, name, localPath string, totalSize int64) error
 	MarkDownloaded(ctx context.Context, id int64, completedAt time.Time) error
 	MarkUploaded(ctx context.Context, id int64, s3Location string, uploadedAt time.Time) error
+	UpdateRateLimits(ctx context.Context, id int64, downloadLimit, uploadLimit int64) error
+	UpdateSeedPolicy(ctx context.Context, id int64, policy *domain.SeedingPolicy) error
+	UpdatePeerUploaded(ctx context.Context, id int64, uploaded int64) error
+	MarkSeeding(ctx context.Context, id int64, startedAt time.Time) error
+	UpdateUploadProgress(ctx context.Context, id int64, done, total, speed int64) error
+	UpdateRetry(ctx context.Context, id int64, attempts int, nextRetryAt *time.Time) error
+	UpdateQueuePriority(ctx context.Context, id int64, priority int) error
+	// MoveInQueue reorders a task among its owner's queued tasks of the same
+	// priority.
+	MoveInQueue(ctx context.Context, userID, id int64, move domain.QueueMove) error
+	SetMetainfo(ctx context.Context, id int64, data []byte) error
+	GetMetainfo(ctx context.Context, id int64) ([]byte, error)
 	Delete(ctx context.Context, id int64) error
+	// DeleteMany removes the tasks in a single transaction; nothing is removed
+	// when one of them does not exist.
+	DeleteMany(ctx context.Context, ids []int64) error
 	Get(ctx context.Context, id int64) (*domain.Task, error)
+	// GetByInfoHash returns the user's task for a torrent.
+	GetByInfoHash(ctx context.Context, userID int64, infoHash string) (*domain.Task, error)
+	// CountByInfoHash counts the tasks of all users for a torrent.
+	CountByInfoHash(ctx context.Context, infoHash string) (int, error)
+	UpdateMagnetURI(ctx context.Context, id int64, magnetURI string) error
+	// AdoptOrphans gives tasks created before tasks had owners (user_id 0) to
+	// userID, skipping torrents the user already has a task for.
+	AdoptOrphans(ctx context.Context, userID int64) (int64, error)
 	List(ctx context.Context) ([]domain.Task, error)
+	ListByUser(ctx context.Context, userID int64) ([]domain.Task, error)
 	ListByStatuses(ctx context.Context, statuses ...domain.TaskStatus) ([]domain.Task, error)
+	ListByUserStatuses(ctx context.Context, userID int64, statuses ...domain.TaskStatus) ([]domain.Task, error)
+	// ListPage returns one page of tasks matching opts. It does not load files.
+	ListPage(ctx context.Context, opts domain.TaskListOptions) (*domain.TaskPage, error)
+	// ListQueue returns the user's queued tasks in queue order.
+	ListQueue(ctx context.Context, userID int64) ([]domain.Task, error)
+	// ListDueRetries returns failed tasks whose automatic retry is due at now.
+	ListDueRetries(ctx context.Context, now time.Time) ([]domain.Task, error)
 }
 
 // TaskFileRepository manages torrent file metadata.
@@ -28,4 +59,9 @@ type TaskFileRepository interface {
 	Init(ctx context.Context) error
 	ReplaceForTask(ctx context.Context, taskID int64, files []domain.TaskFile) error
 	ListByTask(ctx context.Context, taskID int64) ([]domain.TaskFile, error)
+	// ListByTasks loads the files of many tasks at once, keyed by task id.
+	ListByTasks(ctx context.Context, taskIDs []int64) (map[int64][]domain.TaskFile, error)
+	UpdatePriorities(ctx context.Context, taskID int64, priorities map[int64]int) error
+	ResetUploadStatus(ctx context.Context, taskID int64) error
+	UpdateUploadStatus(ctx context.Context, taskID int64, name string, status domain.FileUploadStatus) error
 }
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"context"
+
+	"magnet-player/internal/domain"
+)
+
+// UploadStateRepository persists per-file upload progress of tasks.
+type UploadStateRepository interface {
+	Init(ctx context.Context) error
+	Get(ctx context.Context, taskID int64, key string) (*domain.UploadObject, error)
+	Save(ctx context.Context, obj *domain.UploadObject) error
+	AddPart(ctx context.Context, taskID int64, key string, part domain.UploadPart) error
+	DeleteByTask(ctx context.Context, taskID int64) error
+}
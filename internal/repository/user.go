@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"magnet-player/internal/domain"
 )
@@ -12,4 +13,16 @@ type UserRepository interface {
 	Create(ctx context.Context, user *domain.User) (int64, error)
 	GetByUsername(ctx context.Context, username string) (*domain.User, error)
 	GetByID(ctx context.Context, id int64) (*domain.User, error)
+	List(ctx context.Context) ([]domain.User, error)
+	Count(ctx context.Context) (int, error)
+	CountByRole(ctx context.Context, role domain.UserRole) (int, error)
+	UpdateRole(ctx context.Context, id int64, role domain.UserRole) error
+	SetDisabled(ctx context.Context, id int64, disabled bool) error
+	UpdatePassword(ctx context.Context, id int64, passwordHash string) error
+	Delete(ctx context.Context, id int64) error
+	CreateAPIKey(ctx context.Context, key *domain.APIKey) (int64, error)
+	ListAPIKeys(ctx context.Context, userID int64) ([]domain.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*domain.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error
+	DeleteAPIKey(ctx context.Context, userID, id int64) error
 }
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/repository"
+)
+
+var (
+	// ErrInvalidRefreshToken indicates the refresh token is malformed, expired or revoked.
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrSessionRevoked indicates the session behind an access token is no longer valid.
+	ErrSessionRevoked = errors.New("session revoked")
+)
+
+// SessionService issues and rotates refresh tokens and tracks session revocation.
+type SessionService interface {
+	Create(ctx context.Context, userID int64) (*domain.Session, string, error)
+	Refresh(ctx context.Context, refreshToken string) (*domain.Session, string, error)
+	Validate(ctx context.Context, sessionID string, userID int64) error
+	Revoke(ctx context.Context, sessionID string) error
+	RevokeAll(ctx context.Context, userID int64) error
+	// Prune deletes expired and revoked sessions and reports how many.
+	Prune(ctx context.Context) (int64, error)
+}
+
+type sessionService struct {
+	sessions   repository.SessionRepository
+	refreshTTL time.Duration
+}
+
+func NewSessionService(sessions repository.SessionRepository, refreshTTL time.Duration) SessionService {
+	if refreshTTL <= 0 {
+		refreshTTL = 30 * 24 * time.Hour
+	}
+	return &sessionService{
+		sessions:   sessions,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// Create starts a session for userID and returns it with its first refresh token.
+func (s *sessionService) Create(ctx context.Context, userID int64) (*domain.Session, string, error) {
+	secret, err := newRefreshSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	session := &domain.Session{
+		ID:               uuid.NewString(),
+		UserID:           userID,
+		RefreshTokenHash: hashRefreshSecret(secret),
+		ExpiresAt:        time.Now().Add(s.refreshTTL),
+	}
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, "", err
+	}
+	return session, formatRefreshToken(session.ID, secret), nil
+}
+
+// Refresh exchanges a refresh token for a new one. Presenting a token that was
+// already rotated away revokes the whole session, since it may have leaked.
+func (s *sessionService) Refresh(ctx context.Context, refreshToken string) (*domain.Session, string, error) {
+	sessionID, secret, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessions.Get(ctx, sessionID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, "", ErrInvalidRefreshToken
+		}
+		return nil, "", err
+	}
+	if !session.Active(time.Now()) {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	presented := hashRefreshSecret(secret)
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(session.RefreshTokenHash)) != 1 {
+		if err := s.sessions.Revoke(ctx, session.ID); err != nil {
+			return nil, "", err
+		}
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	next, err := newRefreshSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.sessions.Rotate(ctx, session.ID, presented, hashRefreshSecret(next)); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, "", ErrInvalidRefreshToken
+		}
+		return nil, "", err
+	}
+	return session, formatRefreshToken(session.ID, next), nil
+}
+
+// Validate checks that the session exists, belongs to userID and is still active.
+func (s *sessionService) Validate(ctx context.Context, sessionID string, userID int64) error {
+	if sessionID == "" {
+		return ErrSessionRevoked
+	}
+	session, err := s.sessions.Get(ctx, sessionID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return ErrSessionRevoked
+		}
+		return err
+	}
+	if session.UserID != userID || !session.Active(time.Now()) {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+func (s *sessionService) Revoke(ctx context.Context, sessionID string) error {
+	return s.sessions.Revoke(ctx, sessionID)
+}
+
+func (s *sessionService) RevokeAll(ctx context.Context, userID int64) error {
+	return s.sessions.RevokeAllForUser(ctx, userID)
+}
+
+func (s *sessionService) Prune(ctx context.Context) (int64, error) {
+	return s.sessions.DeleteInactive(ctx, time.Now())
+}
+
+func newRefreshSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashRefreshSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Refresh tokens have the form <session id>.<secret>.
+func formatRefreshToken(sessionID, secret string) string {
+	return sessionID + "." + secret
+}
+
+func parseRefreshToken(token string) (sessionID, secret string, ok bool) {
+	sessionID, secret, ok = strings.Cut(strings.TrimSpace(token), ".")
+	if !ok || sessionID == "" || secret == "" {
+		return "", "", false
+	}
+	return sessionID, secret, true
+}
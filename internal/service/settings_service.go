@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"magnet-player/internal/domain"
+	"magnet-player/internal/repository"
+)
+
+const (
+	settingDownloadLimit         = "bandwidth.download_limit"
+	settingUploadLimit           = "bandwidth.upload_limit"
+	settingScheduleEnabled       = "bandwidth.schedule.enabled"
+	settingScheduleDownloadLimit = "bandwidth.schedule.download_limit"
+	settingScheduleUploadLimit   = "bandwidth.schedule.upload_limit"
+	settingScheduleStart         = "bandwidth.schedule.start"
+	settingScheduleEnd           = "bandwidth.schedule.end"
+	settingScheduleDays          = "bandwidth.schedule.days"
+	settingMaxActive             = "queue.max_active"
+)
+
+// SettingsService stores runtime settings that admins may change without a
+// restart. Values that were never saved fall back to the configured defaults.
+type SettingsService interface {
+	Bandwidth(ctx context.Context) (domain.BandwidthSettings, error)
+	SaveBandwidth(ctx context.Context, settings domain.BandwidthSettings) error
+	// MaxActive is the number of tasks allowed to download at once.
+	MaxActive(ctx context.Context) (int, error)
+	SaveMaxActive(ctx context.Context, maxActive int) error
+}
+
+type settingsService struct {
+	settings  repository.SettingsRepository
+	bandwidth domain.BandwidthSettings
+	maxActive int
+}
+
+func NewSettingsService(settings repository.SettingsRepository, bandwidth domain.BandwidthSettings, maxActive int) SettingsService {
+	return &settingsService{
+		settings:  settings,
+		bandwidth: bandwidth,
+		maxActive: maxActive,
+	}
+}
+
+func (s *settingsService) Bandwidth(ctx context.Context) (domain.BandwidthSettings, error) {
+	values, err := s.settings.List(ctx)
+	if err != nil {
+		return domain.BandwidthSettings{}, err
+	}
+
+	result := s.bandwidth
+	parseInt := func(key string, dst *int64) error {
+		raw, ok := values[key]
+		if !ok {
+			return nil
+		}
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid setting %s: %w", key, err)
+		}
+		*dst = v
+		return nil
+	}
+	parseClock := func(key string, dst *int) error {
+		raw, ok := values[key]
+		if !ok {
+			return nil
+		}
+		v, err := domain.ParseClock(raw)
+		if err != nil {
+			return fmt.Errorf("invalid setting %s: %w", key, err)
+		}
+		*dst = v
+		return nil
+	}
+
+	if err := parseInt(settingDownloadLimit, &result.Limits.Download); err != nil {
+		return domain.BandwidthSettings{}, err
+	}
+	if err := parseInt(settingUploadLimit, &result.Limits.Upload); err != nil {
+		return domain.BandwidthSettings{}, err
+	}
+	if err := parseInt(settingScheduleDownloadLimit, &result.Schedule.Limits.Download); err != nil {
+		return domain.BandwidthSettings{}, err
+	}
+	if err := parseInt(settingScheduleUploadLimit, &result.Schedule.Limits.Upload); err != nil {
+		return domain.BandwidthSettings{}, err
+	}
+	if err := parseClock(settingScheduleStart, &result.Schedule.Start); err != nil {
+		return domain.BandwidthSettings{}, err
+	}
+	if err := parseClock(settingScheduleEnd, &result.Schedule.End); err != nil {
+		return domain.BandwidthSettings{}, err
+	}
+	if raw, ok := values[settingScheduleEnabled]; ok {
+		result.Schedule.Enabled = raw == "true"
+	}
+	if raw, ok := values[settingScheduleDays]; ok {
+		days, err := domain.ParseWeekdays(strings.Split(raw, ","))
+		if err != nil {
+			return domain.BandwidthSettings{}, fmt.Errorf("invalid setting %s: %w", settingScheduleDays, err)
+		}
+		result.Schedule.Days = days
+	}
+	return result, nil
+}
+
+func (s *settingsService) SaveBandwidth(ctx context.Context, settings domain.BandwidthSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	days := make([]string, len(settings.Schedule.Days))
+	for i, day := range settings.Schedule.Days {
+		days[i] = strings.ToLower(day.String()[:3])
+	}
+	return s.settings.Set(ctx, map[string]string{
+		settingDownloadLimit:         strconv.FormatInt(settings.Limits.Download, 10),
+		settingUploadLimit:           strconv.FormatInt(settings.Limits.Upload, 10),
+		settingScheduleEnabled:       strconv.FormatBool(settings.Schedule.Enabled),
+		settingScheduleDownloadLimit: strconv.FormatInt(settings.Schedule.Limits.Download, 10),
+		settingScheduleUploadLimit:   strconv.FormatInt(settings.Schedule.Limits.Upload, 10),
+		settingScheduleStart:         domain.FormatClock(settings.Schedule.Start),
+		settingScheduleEnd:           domain.FormatClock(settings.Schedule.End),
+		settingScheduleDays:          strings.Join(days, ","),
+	})
+}
+
+func (s *settingsService) MaxActive(ctx context.Context) (int, error) {
+	values, err := s.settings.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	raw, ok := values[settingMaxActive]
+	if !ok {
+		return s.maxActive, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid setting %s: %w", settingMaxActive, err)
+	}
+	return v, nil
+}
+
+func (s *settingsService) SaveMaxActive(ctx context.Context, maxActive int) error {
+	if maxActive < 1 {
+		return errors.New("max active downloads must be at least 1")
+	}
+	return s.settings.Set(ctx, map[string]string{
+		settingMaxActive: strconv.Itoa(maxActive),
+	})
+}
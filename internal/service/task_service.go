@@ -1,62 +1,217 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/anacrolix/torrent/metainfo"
+	infohash_v2 "github.com/anacrolix/torrent/types/infohash-v2"
 	"github.com/google/uuid"
 
 	"magnet-player/internal/domain"
 	"magnet-player/internal/repository"
 )
 
+var (
+	// ErrTaskNotFound is returned when a task does not exist or is not visible to the caller.
+	ErrTaskNotFound = errors.New("task not found")
+	// ErrTaskNotQueued is returned when reordering a task that has already finished downloading.
+	ErrTaskNotQueued = errors.New("task is not queued")
+	// ErrDuplicateTask is returned when the user already has a task for the same info-hash.
+	ErrDuplicateTask = errors.New("task already exists")
+	// ErrInvalidCursor is returned for a page cursor that is malformed or was
+	// created for another sort order.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// DuplicateTaskError reports the user's task that already handles a torrent.
+// It matches ErrDuplicateTask.
+type DuplicateTaskError struct {
+	Task *domain.Task
+}
+
+func (e *DuplicateTaskError) Error() string {
+	return fmt.Sprintf("%v: task %d has the same info-hash", ErrDuplicateTask, e.Task.ID)
+}
+
+func (e *DuplicateTaskError) Unwrap() error {
+	return ErrDuplicateTask
+}
+
 // TaskService coordinates task level operations backed by repositories.
 type TaskService interface {
-	CreateTask(ctx context.Context, magnetURI, dataRoot string) (*domain.Task, error)
+	CreateTask(ctx context.Context, userID int64, magnetURI, dataRoot string) (*domain.Task, error)
+	CreateTaskFromTorrent(ctx context.Context, userID int64, data []byte, dataRoot string) (*domain.Task, error)
 	GetTask(ctx context.Context, id int64) (*domain.Task, error)
-	ListTasks(ctx context.Context) ([]domain.Task, error)
+	GetUserTask(ctx context.Context, userID, id int64) (*domain.Task, error)
+	ListTasks(ctx context.Context, userID int64) ([]domain.Task, error)
+	ListTaskPage(ctx context.Context, opts domain.TaskListOptions) (*domain.TaskPage, error)
 	ListByStatuses(ctx context.Context, statuses ...domain.TaskStatus) ([]domain.Task, error)
+	ListUserTasksByStatuses(ctx context.Context, userID int64, statuses ...domain.TaskStatus) ([]domain.Task, error)
 	UpdateStatus(ctx context.Context, id int64, status domain.TaskStatus, errMsg *string) error
 	UpdateDownloadInfo(ctx context.Context, id int64, torrentName, localPath string, totalSize int64) error
 	UpdateProgress(ctx context.Context, id int64, progress int, speed, downloaded int64, totalPeers, activePeers, pendingPeers, connectedSeeders, halfOpenPeers int) error
 	MarkDownloaded(ctx context.Context, id int64) error
 	MarkUploaded(ctx context.Context, id int64, s3Location string) error
 	DeleteTask(ctx context.Context, id int64) error
+	DeleteTasks(ctx context.Context, ids []int64) error
+	// TorrentShared reports whether another task, possibly of another user,
+	// downloads the same torrent as task.
+	TorrentShared(ctx context.Context, task *domain.Task) (bool, error)
 	ReplaceFiles(ctx context.Context, taskID int64, files []domain.TaskFile) error
+	SaveMetainfo(ctx context.Context, id int64, data []byte) error
+	GetMetainfo(ctx context.Context, id int64) ([]byte, error)
+	SetFilePriorities(ctx context.Context, taskID int64, priorities map[int64]int) error
+	SetRateLimits(ctx context.Context, taskID int64, limits domain.BandwidthLimits) error
+	SetSeedPolicy(ctx context.Context, taskID int64, policy *domain.SeedingPolicy) error
+	SetQueuePriority(ctx context.Context, taskID int64, priority int) error
+	MoveInQueue(ctx context.Context, taskID int64, move domain.QueueMove) error
+	ListQueue(ctx context.Context, userID int64) ([]domain.Task, error)
+	UpdatePeerUploaded(ctx context.Context, id int64, uploaded int64) error
+	MarkSeeding(ctx context.Context, id int64) error
+	UpdateUploadProgress(ctx context.Context, id int64, done, total, speed int64) error
+	UpdateRetry(ctx context.Context, id int64, attempts int, nextRetryAt *time.Time) error
+	ListDueRetries(ctx context.Context) ([]domain.Task, error)
+	ResetFileUploadStatus(ctx context.Context, taskID int64) error
+	SetFileUploadStatus(ctx context.Context, taskID int64, name string, status domain.FileUploadStatus) error
+	GetUploadObject(ctx context.Context, taskID int64, key string) (*domain.UploadObject, error)
+	SaveUploadObject(ctx context.Context, obj *domain.UploadObject) error
+	AddUploadPart(ctx context.Context, taskID int64, key string, part domain.UploadPart) error
+	ClearUploadState(ctx context.Context, taskID int64) error
 }
 
 type taskService struct {
-	tasks repository.TaskRepository
-	files repository.TaskFileRepository
+	tasks   repository.TaskRepository
+	files   repository.TaskFileRepository
+	uploads repository.UploadStateRepository
 }
 
-func NewTaskService(tasks repository.TaskRepository, files repository.TaskFileRepository) TaskService {
+func NewTaskService(tasks repository.TaskRepository, files repository.TaskFileRepository, uploads repository.UploadStateRepository) TaskService {
 	return &taskService{
-		tasks: tasks,
-		files: files,
+		tasks:   tasks,
+		files:   files,
+		uploads: uploads,
 	}
 }
 
-func (s *taskService) CreateTask(ctx context.Context, magnetURI, dataRoot string) (*domain.Task, error) {
+func (s *taskService) CreateTask(ctx context.Context, userID int64, magnetURI, dataRoot string) (*domain.Task, error) {
 	if magnetURI == "" {
 		return nil, errors.New("magnet URI is required")
 	}
+	magnet, err := domain.ParseMagnet(magnetURI)
+	if err != nil {
+		return nil, err
+	}
 
 	task := &domain.Task{
-		MagnetURI: magnetURI,
-		Status:    domain.TaskStatusPending,
-		LocalPath: filepath.Join(dataRoot, fmt.Sprintf("task-%s", uuid.NewString())),
+		UserID:       userID,
+		MagnetURI:    magnet.String(),
+		InfoHash:     magnet.Key(),
+		DisplayName:  magnet.DisplayName,
+		ExpectedSize: magnet.ExactLength,
+		Status:       domain.TaskStatusPending,
+		LocalPath:    filepath.Join(dataRoot, fmt.Sprintf("task-%s", uuid.NewString())),
 	}
 
-	if _, err := s.tasks.Create(ctx, task); err != nil {
+	if err := s.create(ctx, task); err != nil {
 		return nil, err
 	}
 	return task, nil
 }
 
+// create inserts a new task unless the user already has one with the same
+// info-hash, in which case the new magnet's trackers are merged into that task
+// and a DuplicateTaskError is returned. Tasks of other users are never touched.
+func (s *taskService) create(ctx context.Context, task *domain.Task) error {
+	existing, err := s.tasks.GetByInfoHash(ctx, task.UserID, task.InfoHash)
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		return err
+	}
+	if existing == nil {
+		_, err := s.tasks.Create(ctx, task)
+		if err == nil {
+			return nil
+		}
+		if !strings.Contains(err.Error(), "already exists") {
+			return err
+		}
+		// a concurrent request added the same torrent first
+		if existing, err = s.tasks.GetByInfoHash(ctx, task.UserID, task.InfoHash); err != nil {
+			return err
+		}
+	}
+
+	if existing.UserID != task.UserID {
+		return fmt.Errorf("task %d belongs to another user", existing.ID)
+	}
+	if merged, added := domain.MergeMagnetTrackers(existing.MagnetURI, task.MagnetURI); added > 0 {
+		if err := s.tasks.UpdateMagnetURI(ctx, existing.ID, merged); err != nil {
+			return err
+		}
+		existing.MagnetURI = merged
+	}
+	return &DuplicateTaskError{Task: existing}
+}
+
+// CreateTaskFromTorrent creates a task from the contents of a .torrent file and
+// keeps the metainfo so the download never depends on fetching metadata from peers.
+func (s *taskService) CreateTaskFromTorrent(ctx context.Context, userID int64, data []byte, dataRoot string) (*domain.Task, error) {
+	if len(data) == 0 {
+		return nil, errors.New("torrent file is required")
+	}
+
+	mi, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent file: %w", err)
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent file: %w", err)
+	}
+	magnet, err := mi.MagnetV2()
+	if err != nil {
+		return nil, fmt.Errorf("invalid torrent file: %w", err)
+	}
+
+	task := &domain.Task{
+		UserID:      userID,
+		MagnetURI:   magnet.String(),
+		InfoHash:    torrentKey(magnet),
+		Status:      domain.TaskStatusPending,
+		TorrentName: info.BestName(),
+		TotalSize:   info.TotalLength(),
+		LocalPath:   filepath.Join(dataRoot, fmt.Sprintf("task-%s", uuid.NewString())),
+	}
+
+	if err := s.create(ctx, task); err != nil {
+		return nil, err
+	}
+	if err := s.tasks.SetMetainfo(ctx, task.ID, data); err != nil {
+		_ = s.tasks.Delete(ctx, task.ID)
+		return nil, err
+	}
+	return task, nil
+}
+
+// torrentKey returns the info-hash a task for the torrent is stored under. It
+// is the key domain.Magnet.Key gives a magnet for the same torrent, so adding
+// it by file and by magnet finds the same task.
+func torrentKey(magnet metainfo.MagnetV2) string {
+	var m domain.Magnet
+	if magnet.InfoHash.Ok {
+		m.InfoHash = magnet.InfoHash.Value.HexString()
+	}
+	if magnet.V2InfoHash.Ok {
+		m.InfoHashV2 = infohash_v2.ToMultihash(magnet.V2InfoHash.Value).HexString()
+	}
+	return m.Key()
+}
+
 func (s *taskService) GetTask(ctx context.Context, id int64) (*domain.Task, error) {
 	task, err := s.tasks.Get(ctx, id)
 	if err != nil {
@@ -70,34 +225,90 @@ func (s *taskService) GetTask(ctx context.Context, id int64) (*domain.Task, erro
 	return task, nil
 }
 
-func (s *taskService) ListTasks(ctx context.Context) ([]domain.Task, error) {
-	tasks, err := s.tasks.List(ctx)
+// GetUserTask loads a task owned by userID.
+func (s *taskService) GetUserTask(ctx context.Context, userID, id int64) (*domain.Task, error) {
+	task, err := s.GetTask(ctx, id)
 	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, ErrTaskNotFound
+		}
 		return nil, err
 	}
+	if task.UserID != userID {
+		return nil, ErrTaskNotFound
+	}
+	return task, nil
+}
 
-	for i := range tasks {
-		files, err := s.files.ListByTask(ctx, tasks[i].ID)
-		if err != nil {
-			return nil, err
-		}
-		tasks[i].Files = files
+func (s *taskService) ListTasks(ctx context.Context, userID int64) ([]domain.Task, error) {
+	tasks, err := s.tasks.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.attachFiles(ctx, tasks); err != nil {
+		return nil, err
 	}
 
 	return tasks, nil
 }
 
+// attachFiles loads the files of all tasks with a single repository call.
+func (s *taskService) attachFiles(ctx context.Context, tasks []domain.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(tasks))
+	for i := range tasks {
+		ids[i] = tasks[i].ID
+	}
+	files, err := s.files.ListByTasks(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		tasks[i].Files = files[tasks[i].ID]
+	}
+	return nil
+}
+
 func (s *taskService) ListByStatuses(ctx context.Context, statuses ...domain.TaskStatus) ([]domain.Task, error) {
 	tasks, err := s.tasks.ListByStatuses(ctx, statuses...)
 	if err != nil {
 		return nil, err
 	}
-	for i := range tasks {
-		files, err := s.files.ListByTask(ctx, tasks[i].ID)
-		if err != nil {
-			return nil, err
+	if err := s.attachFiles(ctx, tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// ListTaskPage returns one page of the tasks matching opts.
+func (s *taskService) ListTaskPage(ctx context.Context, opts domain.TaskListOptions) (*domain.TaskPage, error) {
+	page, err := s.tasks.ListPage(ctx, opts)
+	if err != nil {
+		if strings.Contains(err.Error(), "invalid cursor") {
+			return nil, ErrInvalidCursor
 		}
-		tasks[i].Files = files
+		return nil, err
+	}
+	if opts.OmitFiles {
+		return page, nil
+	}
+	if err := s.attachFiles(ctx, page.Tasks); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// ListUserTasksByStatuses returns the user's tasks in any of the statuses.
+func (s *taskService) ListUserTasksByStatuses(ctx context.Context, userID int64, statuses ...domain.TaskStatus) ([]domain.Task, error) {
+	tasks, err := s.tasks.ListByUserStatuses(ctx, userID, statuses...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachFiles(ctx, tasks); err != nil {
+		return nil, err
 	}
 	return tasks, nil
 }
@@ -126,6 +337,195 @@ func (s *taskService) DeleteTask(ctx context.Context, id int64) error {
 	return s.tasks.Delete(ctx, id)
 }
 
+// DeleteTasks removes several tasks at once: either all of them or none.
+func (s *taskService) DeleteTasks(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.tasks.DeleteMany(ctx, ids)
+}
+
+func (s *taskService) TorrentShared(ctx context.Context, task *domain.Task) (bool, error) {
+	if task.InfoHash == "" {
+		return false, nil
+	}
+	count, err := s.tasks.CountByInfoHash(ctx, task.InfoHash)
+	if err != nil {
+		return false, err
+	}
+	return count > 1, nil
+}
+
 func (s *taskService) ReplaceFiles(ctx context.Context, taskID int64, files []domain.TaskFile) error {
 	return s.files.ReplaceForTask(ctx, taskID, files)
 }
+
+func (s *taskService) SetFilePriorities(ctx context.Context, taskID int64, priorities map[int64]int) error {
+	if len(priorities) == 0 {
+		return errors.New("file priorities are required")
+	}
+
+	files, err := s.files.ListByTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+
+	known := make(map[int64]struct{}, len(files))
+	selected := 0
+	for _, file := range files {
+		known[file.ID] = struct{}{}
+		priority := file.Priority
+		if p, ok := priorities[file.ID]; ok {
+			priority = p
+		}
+		if priority != domain.FilePrioritySkip {
+			selected++
+		}
+	}
+	for fileID, priority := range priorities {
+		if _, ok := known[fileID]; !ok {
+			return fmt.Errorf("task file %d not found", fileID)
+		}
+		if priority < domain.FilePrioritySkip || priority > domain.FilePriorityHigh {
+			return fmt.Errorf("invalid priority %d for file %d", priority, fileID)
+		}
+	}
+	if selected == 0 {
+		return errors.New("at least one file must be selected")
+	}
+
+	return s.files.UpdatePriorities(ctx, taskID, priorities)
+}
+
+// SetRateLimits stores per-task bandwidth caps; zero removes a cap.
+func (s *taskService) SetRateLimits(ctx context.Context, taskID int64, limits domain.BandwidthLimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	if err := s.tasks.UpdateRateLimits(ctx, taskID, limits.Download, limits.Upload); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return ErrTaskNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// SetSeedPolicy overrides the default seeding policy of a task; nil restores the default.
+func (s *taskService) SetSeedPolicy(ctx context.Context, taskID int64, policy *domain.SeedingPolicy) error {
+	if policy != nil {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := s.tasks.UpdateSeedPolicy(ctx, taskID, policy); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return ErrTaskNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *taskService) SetQueuePriority(ctx context.Context, taskID int64, priority int) error {
+	if err := s.tasks.UpdateQueuePriority(ctx, taskID, priority); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return ErrTaskNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *taskService) MoveInQueue(ctx context.Context, taskID int64, move domain.QueueMove) error {
+	task, err := s.tasks.Get(ctx, taskID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return ErrTaskNotFound
+		}
+		return err
+	}
+	if !task.Queued() {
+		return ErrTaskNotQueued
+	}
+	return s.tasks.MoveInQueue(ctx, task.UserID, taskID, move)
+}
+
+func (s *taskService) ListQueue(ctx context.Context, userID int64) ([]domain.Task, error) {
+	tasks, err := s.tasks.ListQueue(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachFiles(ctx, tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (s *taskService) UpdatePeerUploaded(ctx context.Context, id int64, uploaded int64) error {
+	return s.tasks.UpdatePeerUploaded(ctx, id, uploaded)
+}
+
+func (s *taskService) MarkSeeding(ctx context.Context, id int64) error {
+	return s.tasks.MarkSeeding(ctx, id, time.Now())
+}
+
+func (s *taskService) UpdateUploadProgress(ctx context.Context, id int64, done, total, speed int64) error {
+	return s.tasks.UpdateUploadProgress(ctx, id, done, total, speed)
+}
+
+func (s *taskService) UpdateRetry(ctx context.Context, id int64, attempts int, nextRetryAt *time.Time) error {
+	return s.tasks.UpdateRetry(ctx, id, attempts, nextRetryAt)
+}
+
+func (s *taskService) ListDueRetries(ctx context.Context) ([]domain.Task, error) {
+	tasks, err := s.tasks.ListDueRetries(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachFiles(ctx, tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (s *taskService) ResetFileUploadStatus(ctx context.Context, taskID int64) error {
+	return s.files.ResetUploadStatus(ctx, taskID)
+}
+
+func (s *taskService) SetFileUploadStatus(ctx context.Context, taskID int64, name string, status domain.FileUploadStatus) error {
+	return s.files.UpdateUploadStatus(ctx, taskID, name, status)
+}
+
+func (s *taskService) SaveMetainfo(ctx context.Context, id int64, data []byte) error {
+	return s.tasks.SetMetainfo(ctx, id, data)
+}
+
+func (s *taskService) GetMetainfo(ctx context.Context, id int64) ([]byte, error) {
+	return s.tasks.GetMetainfo(ctx, id)
+}
+
+// GetUploadObject returns the recorded upload state of key, or nil when nothing was recorded.
+func (s *taskService) GetUploadObject(ctx context.Context, taskID int64, key string) (*domain.UploadObject, error) {
+	obj, err := s.uploads.Get(ctx, taskID, key)
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (s *taskService) SaveUploadObject(ctx context.Context, obj *domain.UploadObject) error {
+	return s.uploads.Save(ctx, obj)
+}
+
+func (s *taskService) AddUploadPart(ctx context.Context, taskID int64, key string, part domain.UploadPart) error {
+	return s.uploads.AddPart(ctx, taskID, key, part)
+}
+
+// ClearUploadState forgets the upload progress of a task once it no longer needs resuming.
+func (s *taskService) ClearUploadState(ctx context.Context, taskID int64) error {
+	return s.uploads.DeleteByTask(ctx, taskID)
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	infohash_v2 "github.com/anacrolix/torrent/types/infohash-v2"
+
+	"magnet-player/internal/repository/sqlite"
+)
+
+func newTestTaskService(t *testing.T) TaskService {
+	t.Helper()
+	ctx := context.Background()
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	tasks := sqlite.NewTaskRepository(db)
+	if err := tasks.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	files := sqlite.NewTaskFileRepository(db)
+	if err := files.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return NewTaskService(tasks, files, nil)
+}
+
+// torrentFile returns a single file torrent with v1 info, v2 info or both,
+// and a magnet naming it by the given hash kinds.
+func torrentFile(t *testing.T, v1, v2, btih, btmh bool) ([]byte, string) {
+	t.Helper()
+	const length = 10
+	info := metainfo.Info{Name: "a.txt", PieceLength: 16384}
+	if v1 {
+		info.Length = length
+		info.Pieces = make([]byte, sha1.Size)
+	}
+	if v2 {
+		info.MetaVersion = 2
+		info.FileTree = metainfo.FileTree{Dir: map[string]metainfo.FileTree{
+			"a.txt": {File: metainfo.FileTreeFile{Length: length, PiecesRoot: strings.Repeat("r", 32)}},
+		}}
+	}
+	infoBytes, err := bencode.Marshal(&info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := bencode.Marshal(metainfo.MetaInfo{InfoBytes: infoBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	magnet := "magnet:?dn=a.txt"
+	if btih {
+		magnet += "&xt=urn:btih:" + metainfo.HashBytes(infoBytes).HexString()
+	}
+	if btmh {
+		magnet += "&xt=urn:btmh:" + infohash_v2.ToMultihash(infohash_v2.HashBytes(infoBytes)).HexString()
+	}
+	return data, magnet
+}
+
+func TestCreateTaskDuplicateAcrossMagnetAndFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		v1, v2     bool
+		btih, btmh bool
+	}{
+		{"v1", true, false, true, false},
+		{"v2", false, true, false, true},
+		{"hybrid named by btih", true, true, true, false},
+		{"hybrid named by both", true, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, magnet := torrentFile(t, tt.v1, tt.v2, tt.btih, tt.btmh)
+
+			t.Run("magnet first", func(t *testing.T) {
+				ctx := context.Background()
+				svc := newTestTaskService(t)
+				first, err := svc.CreateTask(ctx, 1, magnet, t.TempDir())
+				if err != nil {
+					t.Fatalf("CreateTask: %v", err)
+				}
+				_, err = svc.CreateTaskFromTorrent(ctx, 1, data, t.TempDir())
+				var dup *DuplicateTaskError
+				if !errors.As(err, &dup) || dup.Task.ID != first.ID {
+					t.Errorf("CreateTaskFromTorrent = %v, want a duplicate of task %d", err, first.ID)
+				}
+			})
+			t.Run("file first", func(t *testing.T) {
+				ctx := context.Background()
+				svc := newTestTaskService(t)
+				first, err := svc.CreateTaskFromTorrent(ctx, 1, data, t.TempDir())
+				if err != nil {
+					t.Fatalf("CreateTaskFromTorrent: %v", err)
+				}
+				_, err = svc.CreateTask(ctx, 1, magnet, t.TempDir())
+				var dup *DuplicateTaskError
+				if !errors.As(err, &dup) || dup.Task.ID != first.ID {
+					t.Errorf("CreateTask = %v, want a duplicate of task %d", err, first.ID)
+				}
+			})
+		})
+	}
+}
@@ -2,7 +2,11 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strings"
@@ -21,24 +25,56 @@ var (
 	ErrInvalidRegistrationPassword = errors.New("invalid registration password")
 	// ErrUserAlreadyExists is returned when attempting to register with an existing username.
 	ErrUserAlreadyExists = errors.New("user already exists")
+	// ErrUserDisabled indicates the account has been disabled by an administrator.
+	ErrUserDisabled = errors.New("user disabled")
+	// ErrUserNotFound is returned when the requested user does not exist.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrLastAdmin prevents removing the last active administrator.
+	ErrLastAdmin = errors.New("cannot remove the last administrator")
+	// ErrUserHasTasks prevents deleting a user whose tasks still exist.
+	ErrUserHasTasks = errors.New("user still owns tasks")
+	// ErrInvalidAPIKey indicates the presented API key is unknown or malformed.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrAPIKeyNotFound is returned when revoking a key the user does not own.
+	ErrAPIKeyNotFound = errors.New("api key not found")
 )
 
+// apiKeyTouchInterval limits how often last-used timestamps are written.
+const apiKeyTouchInterval = time.Minute
+
 // UserService describes user lifecycle operations.
 type UserService interface {
 	Register(ctx context.Context, username, password, providedSecret string) (*domain.User, error)
 	Authenticate(ctx context.Context, username, password string) (*domain.User, error)
 	GetByID(ctx context.Context, id int64) (*domain.User, error)
+	EnsureAdmin(ctx context.Context) error
+	ListUsers(ctx context.Context) ([]domain.User, error)
+	SetRole(ctx context.Context, id int64, role domain.UserRole) (*domain.User, error)
+	SetDisabled(ctx context.Context, id int64, disabled bool) (*domain.User, error)
+	ResetPassword(ctx context.Context, id int64, password string) error
+	DeleteUser(ctx context.Context, id int64) error
+	CreateAPIKey(ctx context.Context, userID int64, name string, scopes []domain.APIKeyScope) (*domain.APIKey, string, error)
+	ListAPIKeys(ctx context.Context, userID int64) ([]domain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id int64) error
+	AuthenticateAPIKey(ctx context.Context, rawKey string) (*domain.User, *domain.APIKey, error)
 }
 
 type userService struct {
 	users          repository.UserRepository
+	tasks          repository.TaskRepository
 	registerSecret string
+	adminUsername  string
 }
 
-func NewUserService(users repository.UserRepository, registerSecret string) UserService {
+// NewUserService builds a UserService. The first registered user, or the user
+// named adminUsername when set, is granted the admin role. The admin also
+// takes over tasks created before tasks had owners.
+func NewUserService(users repository.UserRepository, tasks repository.TaskRepository, registerSecret, adminUsername string) UserService {
 	return &userService{
 		users:          users,
+		tasks:          tasks,
 		registerSecret: strings.TrimSpace(registerSecret),
+		adminUsername:  strings.TrimSpace(adminUsername),
 	}
 }
 
@@ -50,11 +86,8 @@ func (s *userService) Register(ctx context.Context, username, password, provided
 	if username == "" {
 		return nil, errors.New("username is required")
 	}
-	if password == "" {
-		return nil, errors.New("password is required")
-	}
-	if len(password) < 8 {
-		return nil, errors.New("password must be at least 8 characters")
+	if err := validatePassword(password); err != nil {
+		return nil, err
 	}
 	if s.registerSecret == "" {
 		return nil, fmt.Errorf("registration secret is not configured")
@@ -68,9 +101,19 @@ func (s *userService) Register(ctx context.Context, username, password, provided
 		return nil, fmt.Errorf("hash password: %w", err)
 	}
 
+	role := domain.UserRoleUser
+	if s.adminUsername != "" && username == s.adminUsername {
+		role = domain.UserRoleAdmin
+	} else if count, err := s.users.Count(ctx); err != nil {
+		return nil, err
+	} else if count == 0 {
+		role = domain.UserRoleAdmin
+	}
+
 	user := &domain.User{
 		Username:     username,
 		PasswordHash: string(hash),
+		Role:         role,
 		CreatedAt:    time.Now().UTC(),
 		UpdatedAt:    time.Now().UTC(),
 	}
@@ -81,6 +124,11 @@ func (s *userService) Register(ctx context.Context, username, password, provided
 		}
 		return nil, err
 	}
+	if role == domain.UserRoleAdmin {
+		if err := s.adoptOrphanedTasks(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
 
 	return sanitizeUser(user), nil
 }
@@ -103,6 +151,9 @@ func (s *userService) Authenticate(ctx context.Context, username, password strin
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
 		return nil, ErrInvalidCredentials
 	}
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
 
 	return sanitizeUser(user), nil
 }
@@ -110,11 +161,300 @@ func (s *userService) Authenticate(ctx context.Context, username, password strin
 func (s *userService) GetByID(ctx context.Context, id int64) (*domain.User, error) {
 	user, err := s.users.GetByID(ctx, id)
 	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return nil, ErrUserNotFound
+		}
 		return nil, err
 	}
 	return sanitizeUser(user), nil
 }
 
+// EnsureAdmin promotes a user to admin when none exists yet, preferring the
+// configured admin username and falling back to the oldest account. The
+// oldest admin then takes over tasks that have no owner.
+func (s *userService) EnsureAdmin(ctx context.Context) error {
+	admin, err := s.ensureAdmin(ctx)
+	if err != nil || admin == 0 {
+		return err
+	}
+	return s.adoptOrphanedTasks(ctx, admin)
+}
+
+// ensureAdmin returns the id of the oldest admin, promoting one first when
+// needed. It returns zero when there are no users yet.
+func (s *userService) ensureAdmin(ctx context.Context) (int64, error) {
+	users, err := s.users.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, user := range users {
+		if user.Role == domain.UserRoleAdmin {
+			return user.ID, nil
+		}
+	}
+
+	if s.adminUsername != "" {
+		user, err := s.users.GetByUsername(ctx, s.adminUsername)
+		if err == nil {
+			return user.ID, s.users.UpdateRole(ctx, user.ID, domain.UserRoleAdmin)
+		}
+		if !strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return 0, err
+		}
+	}
+
+	if len(users) == 0 {
+		return 0, nil
+	}
+	return users[0].ID, s.users.UpdateRole(ctx, users[0].ID, domain.UserRoleAdmin)
+}
+
+// adoptOrphanedTasks gives tasks created before tasks had owners to the admin.
+func (s *userService) adoptOrphanedTasks(ctx context.Context, adminID int64) error {
+	if s.tasks == nil {
+		return nil
+	}
+	if _, err := s.tasks.AdoptOrphans(ctx, adminID); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *userService) ListUsers(ctx context.Context) ([]domain.User, error) {
+	users, err := s.users.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		users[i] = *sanitizeUser(&users[i])
+	}
+	return users, nil
+}
+
+func (s *userService) SetRole(ctx context.Context, id int64, role domain.UserRole) (*domain.User, error) {
+	if role != domain.UserRoleAdmin && role != domain.UserRoleUser {
+		return nil, fmt.Errorf("invalid role %q", role)
+	}
+	user, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == role {
+		return user, nil
+	}
+	if user.IsAdmin() {
+		if err := s.ensureOtherAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.users.UpdateRole(ctx, id, role); err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id)
+}
+
+func (s *userService) SetDisabled(ctx context.Context, id int64, disabled bool) (*domain.User, error) {
+	user, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user.Disabled == disabled {
+		return user, nil
+	}
+	if disabled && user.IsAdmin() {
+		if err := s.ensureOtherAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.users.SetDisabled(ctx, id, disabled); err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id)
+}
+
+func (s *userService) ResetPassword(ctx context.Context, id int64, password string) error {
+	password = strings.TrimSpace(password)
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	return s.users.UpdatePassword(ctx, id, string(hash))
+}
+
+// DeleteUser removes the user with their sessions and API keys. Their tasks
+// must be deleted first.
+func (s *userService) DeleteUser(ctx context.Context, id int64) error {
+	user, err := s.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.IsAdmin() {
+		if err := s.ensureOtherAdmin(ctx); err != nil {
+			return err
+		}
+	}
+	if s.tasks != nil {
+		tasks, err := s.tasks.ListByUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		if len(tasks) > 0 {
+			return ErrUserHasTasks
+		}
+	}
+	return s.users.Delete(ctx, id)
+}
+
+// CreateAPIKey issues a new personal key for userID. The plain key is only
+// returned here; afterwards only its hash is kept.
+func (s *userService) CreateAPIKey(ctx context.Context, userID int64, name string, scopes []domain.APIKeyScope) (*domain.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errors.New("api key name is required")
+	}
+	if _, err := s.GetByID(ctx, userID); err != nil {
+		return nil, "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", fmt.Errorf("generate api key: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+	rawKey := domain.APIKeyPrefix + secret
+
+	key := &domain.APIKey{
+		UserID:  userID,
+		Name:    name,
+		Prefix:  domain.APIKeyPrefix + secret[:8],
+		KeyHash: hashAPIKey(rawKey),
+		Scopes:  dedupeScopes(scopes),
+	}
+	if _, err := s.users.CreateAPIKey(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return sanitizeAPIKey(key), rawKey, nil
+}
+
+func (s *userService) ListAPIKeys(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	keys, err := s.users.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range keys {
+		keys[i] = *sanitizeAPIKey(&keys[i])
+	}
+	return keys, nil
+}
+
+func (s *userService) RevokeAPIKey(ctx context.Context, userID, id int64) error {
+	if err := s.users.DeleteAPIKey(ctx, userID, id); err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return ErrAPIKeyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// AuthenticateAPIKey resolves a raw key to its owner and records its use.
+func (s *userService) AuthenticateAPIKey(ctx context.Context, rawKey string) (*domain.User, *domain.APIKey, error) {
+	rawKey = strings.TrimSpace(rawKey)
+	if !strings.HasPrefix(rawKey, domain.APIKeyPrefix) || len(rawKey) == len(domain.APIKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.users.GetAPIKeyByHash(ctx, hashAPIKey(rawKey))
+	if err != nil {
+		if strings.Contains(strings.ToLower(err.Error()), "not found") {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+
+	user, err := s.GetByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, nil, ErrInvalidAPIKey
+		}
+		return nil, nil, err
+	}
+	if user.Disabled {
+		return nil, nil, ErrUserDisabled
+	}
+
+	now := time.Now().UTC()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.users.TouchAPIKey(ctx, key.ID, now); err != nil {
+			return nil, nil, err
+		}
+		key.LastUsedAt = &now
+	}
+	return user, sanitizeAPIKey(key), nil
+}
+
+// ensureOtherAdmin fails when demoting one admin would leave no enabled admin behind.
+func (s *userService) ensureOtherAdmin(ctx context.Context) error {
+	users, err := s.users.List(ctx)
+	if err != nil {
+		return err
+	}
+	admins := 0
+	for _, user := range users {
+		if user.IsAdmin() && !user.Disabled {
+			admins++
+		}
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+func validatePassword(password string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+	if len(password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+	return nil
+}
+
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func dedupeScopes(scopes []domain.APIKeyScope) []domain.APIKeyScope {
+	seen := make(map[domain.APIKeyScope]struct{}, len(scopes))
+	var out []domain.APIKeyScope
+	for _, scope := range scopes {
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		out = append(out, scope)
+	}
+	return out
+}
+
+func sanitizeAPIKey(key *domain.APIKey) *domain.APIKey {
+	if key == nil {
+		return nil
+	}
+	clean := *key
+	clean.KeyHash = ""
+	return &clean
+}
+
 func sanitizeUser(user *domain.User) *domain.User {
 	if user == nil {
 		return nil
@@ -122,6 +462,8 @@ func sanitizeUser(user *domain.User) *domain.User {
 	return &domain.User{
 		ID:        user.ID,
 		Username:  user.Username,
+		Role:      user.Role,
+		Disabled:  user.Disabled,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 	}
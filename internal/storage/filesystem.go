@@ -0,0 +1,360 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"magnet-player/internal/domain"
+)
+
+// LocalScheme prefixes locations returned by FilesystemService.UploadDirectory.
+const LocalScheme = "local://"
+
+// ErrInvalidSignature is returned when a presigned local URL is malformed, tampered with or expired.
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// LocalObjectServer is implemented by backends whose objects are delivered by
+// this server instead of by the storage provider.
+type LocalObjectServer interface {
+	OpenObject(key string) (*os.File, error)
+	VerifyPresigned(key string, query url.Values) (PresignOptions, error)
+}
+
+// FilesystemService keeps uploaded task data in a library directory on local
+// disk. The bucket arguments of Service are ignored.
+type FilesystemService struct {
+	root       string
+	baseURL    string
+	signingKey []byte
+}
+
+// NewFilesystemService stores objects below root. Presigned URLs point at
+// baseURL/<key> and are signed with signingKey.
+func NewFilesystemService(root, baseURL string, signingKey []byte) (*FilesystemService, error) {
+	root = filepath.Clean(root)
+	if root == "" || root == "." {
+		return nil, fmt.Errorf("storage root is required")
+	}
+	if len(signingKey) == 0 {
+		return nil, fmt.Errorf("storage signing key is required")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("create storage root: %w", err)
+	}
+	return &FilesystemService{
+		root:       root,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		signingKey: signingKey,
+	}, nil
+}
+
+// UploadDirectory moves the files into the library, falling back to a copy
+// when the library lives on a different device or the source must be kept.
+func (s *FilesystemService) UploadDirectory(ctx context.Context, localPath string, opts UploadOptions) (string, error) {
+	src := filepath.Clean(localPath)
+	if fi, err := os.Stat(src); err != nil {
+		return "", fmt.Errorf("stat local path: %w", err)
+	} else if !fi.IsDir() {
+		return "", fmt.Errorf("local path must be a directory")
+	}
+
+	keyPrefix := strings.Trim(opts.KeyPrefix, "/")
+	if keyPrefix == "" {
+		keyPrefix = fmt.Sprintf("task-%d", os.Getpid())
+	}
+	destRoot, err := s.resolve(keyPrefix)
+	if err != nil {
+		return "", err
+	}
+
+	var include map[string]struct{}
+	if len(opts.Files) > 0 {
+		include = make(map[string]struct{}, len(opts.Files))
+		for _, name := range opts.Files {
+			include[strings.Trim(filepath.ToSlash(name), "/")] = struct{}{}
+		}
+	}
+
+	type localFile struct {
+		path string
+		rel  string
+		size int64
+	}
+	var files []localFile
+	err = filepath.Walk(src, func(p string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return fmt.Errorf("relative path for %s: %w", p, err)
+		}
+		if include != nil {
+			if _, ok := include[filepath.ToSlash(rel)]; !ok {
+				return nil
+			}
+		}
+		files = append(files, localFile{path: p, rel: rel, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.size
+	}
+	progress := newProgressReporter(totalSize, opts.ProgressCallback)
+	if progress != nil {
+		progress.report(0)
+	}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		dest := filepath.Join(destRoot, file.rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return "", fmt.Errorf("create dir for %s: %w", dest, err)
+		}
+		name := filepath.ToSlash(file.rel)
+		opts.reportFile(name, domain.FileUploadUploading)
+		if !opts.KeepSource {
+			if err := os.Rename(file.path, dest); err == nil {
+				if progress != nil {
+					progress.advance(file.size)
+				}
+				opts.reportFile(name, domain.FileUploadUploaded)
+				continue
+			}
+		}
+		if err := copyLocalFile(ctx, file.path, dest, progress); err != nil {
+			return "", fmt.Errorf("copy %s: %w", file.path, err)
+		}
+		opts.reportFile(name, domain.FileUploadUploaded)
+	}
+
+	if progress != nil {
+		progress.flush()
+	}
+
+	return LocalScheme + keyPrefix, nil
+}
+
+func (s *FilesystemService) ListObjects(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	prefix = strings.TrimPrefix(strings.TrimSpace(prefix), "/")
+
+	// only walk the deepest directory the prefix fully names
+	start := s.root
+	if dir := path.Dir(prefix); dir != "." && prefix != "" {
+		resolved, err := s.resolve(dir)
+		if err != nil {
+			return nil, err
+		}
+		start = resolved
+	}
+
+	var objects []ObjectInfo
+	err := filepath.Walk(start, func(p string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			if os.IsNotExist(walkErr) {
+				return filepath.SkipDir
+			}
+			return walkErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return fmt.Errorf("relative path for %s: %w", p, err)
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		modified := info.ModTime()
+		objects = append(objects, ObjectInfo{
+			Key:          key,
+			Size:         info.Size(),
+			LastModified: &modified,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list objects: %w", err)
+	}
+	return objects, nil
+}
+
+func (s *FilesystemService) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	trimmed := strings.Trim(strings.TrimSpace(prefix), "/")
+	if trimmed == "" {
+		return fmt.Errorf("prefix is required")
+	}
+
+	objects, err := s.ListObjects(ctx, bucket, trimmed)
+	if err != nil {
+		return err
+	}
+	dirs := make(map[string]struct{})
+	for _, obj := range objects {
+		p, err := s.resolve(obj.Key)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("delete %s: %w", obj.Key, err)
+		}
+		for dir := filepath.Dir(p); dir != s.root && strings.HasPrefix(dir, s.root); dir = filepath.Dir(dir) {
+			dirs[dir] = struct{}{}
+		}
+	}
+
+	// remove directories left empty, deepest first
+	ordered := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		ordered = append(ordered, dir)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return len(ordered[i]) > len(ordered[j]) })
+	for _, dir := range ordered {
+		_ = os.Remove(dir)
+	}
+	return nil
+}
+
+// PresignGet returns a URL served by this application that is valid until ttl elapses.
+func (s *FilesystemService) PresignGet(ctx context.Context, bucket, key string, ttl time.Duration, opts PresignOptions) (string, error) {
+	key = strings.TrimPrefix(strings.TrimSpace(key), "/")
+	if key == "" {
+		return "", fmt.Errorf("object key is required")
+	}
+	if ttl <= 0 {
+		return "", fmt.Errorf("presign ttl must be positive")
+	}
+	if _, err := s.resolve(key); err != nil {
+		return "", err
+	}
+
+	expires := time.Now().Add(ttl).Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	if opts.ContentDisposition != "" {
+		query.Set("disposition", opts.ContentDisposition)
+	}
+	query.Set("signature", s.sign(key, expires, opts.ContentDisposition))
+
+	escaped := strings.Split(key, "/")
+	for i := range escaped {
+		escaped[i] = url.PathEscape(escaped[i])
+	}
+	return s.baseURL + "/" + strings.Join(escaped, "/") + "?" + query.Encode(), nil
+}
+
+// VerifyPresigned checks the query of a URL produced by PresignGet and returns
+// the options it was signed with.
+func (s *FilesystemService) VerifyPresigned(key string, query url.Values) (PresignOptions, error) {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return PresignOptions{}, ErrInvalidSignature
+	}
+	opts := PresignOptions{ContentDisposition: query.Get("disposition")}
+	expected := s.sign(key, expires, opts.ContentDisposition)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("signature"))) {
+		return PresignOptions{}, ErrInvalidSignature
+	}
+	return opts, nil
+}
+
+// OpenObject opens the file stored under key.
+func (s *FilesystemService) OpenObject(key string) (*os.File, error) {
+	p, err := s.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("open object: %w", err)
+	}
+	if fi, err := f.Stat(); err != nil || fi.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("open object: %s is not a file", key)
+	}
+	return f, nil
+}
+
+func (s *FilesystemService) sign(key string, expires int64, disposition string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "%s\n%d\n%s", key, expires, disposition)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// resolve maps key to a path inside the library root, rejecting keys that escape it.
+func (s *FilesystemService) resolve(key string) (string, error) {
+	p := filepath.Join(s.root, filepath.FromSlash(key))
+	rel, err := filepath.Rel(s.root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return p, nil
+}
+
+func copyLocalFile(ctx context.Context, src, dest string, progress *progressReporter) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	var reader io.Reader = in
+	if progress != nil {
+		reader = io.TeeReader(in, progress)
+	}
+	if _, err := io.Copy(out, &contextReader{ctx: ctx, r: reader}); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+var (
+	_ Service           = (*FilesystemService)(nil)
+	_ LocalObjectServer = (*FilesystemService)(nil)
+)
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimitChunk bounds a single read so one large buffer cannot hog the
+// limiter; it is also the smallest burst a limiter is given.
+const rateLimitChunk = 32 << 10
+
+// newUploadLimiter returns a limiter of bytesPerSecond; zero disables limiting.
+func newUploadLimiter(bytesPerSecond int64) *rate.Limiter {
+	l := rate.NewLimiter(rate.Inf, rateLimitChunk)
+	setUploadLimit(l, bytesPerSecond)
+	return l
+}
+
+// setUploadLimit retunes l, allowing a burst of one second worth of bytes.
+func setUploadLimit(l *rate.Limiter, bytesPerSecond int64) {
+	if bytesPerSecond <= 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	l.SetBurst(int(max(bytesPerSecond, rateLimitChunk)))
+	l.SetLimit(rate.Limit(bytesPerSecond))
+}
+
+// limitedReader throttles reads through a shared limiter.
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateLimitChunk {
+		p = p[:rateLimitChunk]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// limitedReadSeeker keeps the body seekable so the SDK can rewind it on retries.
+type limitedReadSeeker struct {
+	limitedReader
+	seeker io.Seeker
+}
+
+func (r *limitedReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.seeker.Seek(offset, whence)
+}
+
+func newLimitedReadSeeker(ctx context.Context, rs io.ReadSeeker, limiter *rate.Limiter) io.ReadSeeker {
+	if limiter == nil {
+		return rs
+	}
+	return &limitedReadSeeker{
+		limitedReader: limitedReader{ctx: ctx, r: rs, limiter: limiter},
+		seeker:        rs,
+	}
+}
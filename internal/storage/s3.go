@@ -3,7 +3,6 @@ package storage
 import (
 	"context"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,21 +13,54 @@ import (
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/time/rate"
+
+	"magnet-player/internal/domain"
 )
 
+// S3Options tunes upload throughput of S3Service. Zero values keep the defaults.
+type S3Options struct {
+	// FileConcurrency is the number of files of a directory uploaded at once.
+	FileConcurrency int
+	// PartSize is the multipart chunk size in bytes; S3 requires at least 5 MiB.
+	PartSize int64
+	// PartConcurrency is the number of parts of one file uploaded at once.
+	PartConcurrency int
+	// RateLimit caps the combined upload throughput of all tasks in bytes per second.
+	RateLimit int64
+}
+
 // S3Service uploads task data to Amazon S3 (or compatible APIs).
 type S3Service struct {
-	client   *s3.Client
-	uploader *manager.Uploader
+	client    *s3.Client
+	presigner *s3.PresignClient
+	opts      S3Options
+	limiter   *rate.Limiter
 }
 
-func NewS3Service(client *s3.Client) *S3Service {
+func NewS3Service(client *s3.Client, opts S3Options) *S3Service {
+	if opts.FileConcurrency <= 0 {
+		opts.FileConcurrency = 1
+	}
+	if opts.PartSize < manager.MinUploadPartSize {
+		opts.PartSize = defaultPartSize
+	}
+	if opts.PartConcurrency <= 0 {
+		opts.PartConcurrency = manager.DefaultUploadConcurrency
+	}
 	return &S3Service{
-		client:   client,
-		uploader: manager.NewUploader(client),
+		client:    client,
+		presigner: s3.NewPresignClient(client),
+		opts:      opts,
+		limiter:   newUploadLimiter(opts.RateLimit),
 	}
 }
 
+// SetUploadRateLimit changes the shared upload limit in bytes per second; zero removes it.
+func (s *S3Service) SetUploadRateLimit(bytesPerSecond int64) {
+	setUploadLimit(s.limiter, bytesPerSecond)
+}
+
 func (s *S3Service) UploadDirectory(ctx context.Context, localPath string, opts UploadOptions) (string, error) {
 	if opts.Bucket == "" {
 		return "", fmt.Errorf("storage bucket is required")
@@ -47,6 +79,14 @@ func (s *S3Service) UploadDirectory(ctx context.Context, localPath string, opts
 		size int64
 	}
 
+	var include map[string]struct{}
+	if len(opts.Files) > 0 {
+		include = make(map[string]struct{}, len(opts.Files))
+		for _, name := range opts.Files {
+			include[strings.Trim(filepath.ToSlash(name), "/")] = struct{}{}
+		}
+	}
+
 	var files []uploadFile
 	err := filepath.Walk(root, func(path string, info os.FileInfo, walkErr error) error {
 		if walkErr != nil {
@@ -59,6 +99,11 @@ func (s *S3Service) UploadDirectory(ctx context.Context, localPath string, opts
 		if err != nil {
 			return fmt.Errorf("relative path for %s: %w", path, err)
 		}
+		if include != nil {
+			if _, ok := include[filepath.ToSlash(rel)]; !ok {
+				return nil
+			}
+		}
 		files = append(files, uploadFile{
 			path: path,
 			rel:  filepath.ToSlash(rel),
@@ -85,6 +130,15 @@ func (s *S3Service) UploadDirectory(ctx context.Context, localPath string, opts
 		keyPrefix = fmt.Sprintf("task-%d", os.Getpid())
 	}
 
+	uploadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+		sem      = make(chan struct{}, s.opts.FileConcurrency)
+	)
 	for _, file := range files {
 		key := keyPrefix
 		if file.rel != "" && file.rel != "." {
@@ -98,27 +152,36 @@ func (s *S3Service) UploadDirectory(ctx context.Context, localPath string, opts
 			key = filepath.ToSlash(filepath.Base(file.path))
 		}
 
-		f, err := os.Open(file.path)
-		if err != nil {
-			return "", fmt.Errorf("open file %s: %w", file.path, err)
+		select {
+		case sem <- struct{}{}:
+		case <-uploadCtx.Done():
 		}
-		var reader io.Reader = f
-		if progress != nil {
-			reader = io.TeeReader(f, progress)
-		}
-		_, err = s.uploader.Upload(ctx, &s3.PutObjectInput{
-			Bucket: aws.String(opts.Bucket),
-			Key:    aws.String(key),
-			Body:   reader,
-			ACL:    types.ObjectCannedACLPrivate,
-		})
-		closeErr := f.Close()
-		if err != nil {
-			return "", fmt.Errorf("upload %s: %w", file.path, err)
-		}
-		if closeErr != nil {
-			return "", fmt.Errorf("close file %s: %w", file.path, closeErr)
+		if uploadCtx.Err() != nil {
+			break
 		}
+
+		wg.Add(1)
+		go func(file uploadFile, key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			opts.reportFile(file.rel, domain.FileUploadUploading)
+			if err := s.uploadFile(uploadCtx, opts, key, file.path, file.size, progress); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			opts.reportFile(file.rel, domain.FileUploadUploaded)
+		}(file, key)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
 
 	if progress != nil {
@@ -128,6 +191,19 @@ func (s *S3Service) UploadDirectory(ctx context.Context, localPath string, opts
 	return fmt.Sprintf("s3://%s/%s", opts.Bucket, keyPrefix), nil
 }
 
+// uploadFile uploads through the resumable path, which also applies the rate
+// limit. Without opts.State progress is only kept for this call.
+func (s *S3Service) uploadFile(ctx context.Context, opts UploadOptions, key, localPath string, size int64, progress *progressReporter) error {
+	state := opts.State
+	if state == nil {
+		state = discardUploadState{}
+	}
+	if err := s.uploadResumable(ctx, opts.Bucket, key, localPath, size, state, progress); err != nil {
+		return fmt.Errorf("upload %s: %w", localPath, err)
+	}
+	return nil
+}
+
 func (s *S3Service) ListObjects(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
 	if bucket == "" {
 		return nil, fmt.Errorf("storage bucket is required")
@@ -216,6 +292,32 @@ func (s *S3Service) DeletePrefix(ctx context.Context, bucket, prefix string) err
 	return nil
 }
 
+func (s *S3Service) PresignGet(ctx context.Context, bucket, key string, ttl time.Duration, opts PresignOptions) (string, error) {
+	if bucket == "" {
+		return "", fmt.Errorf("storage bucket is required")
+	}
+	if strings.TrimSpace(key) == "" {
+		return "", fmt.Errorf("object key is required")
+	}
+	if ttl <= 0 {
+		return "", fmt.Errorf("presign ttl must be positive")
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if opts.ContentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(opts.ContentDisposition)
+	}
+
+	req, err := s.presigner.PresignGetObject(ctx, input, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("presign get object: %w", err)
+	}
+	return req.URL, nil
+}
+
 var _ Service = (*S3Service)(nil)
 
 type progressReporter struct {
@@ -240,17 +342,24 @@ func (p *progressReporter) Write(b []byte) (int, error) {
 	if len(b) == 0 {
 		return 0, nil
 	}
+	p.advance(int64(len(b)))
+	return len(b), nil
+}
+
+// advance records n more bytes as done. It is safe to call on a nil reporter.
+func (p *progressReporter) advance(n int64) {
+	if p == nil {
+		return
+	}
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.done += int64(len(b))
+	p.done += n
 	now := time.Now()
 	if now.Sub(p.lastFire) >= 200*time.Millisecond || p.done == p.total {
 		p.lastFire = now
 		p.cb(p.done, p.total)
 	}
-
-	return len(b), nil
 }
 
 func (p *progressReporter) report(done int64) {
@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"magnet-player/internal/domain"
+)
+
+const (
+	defaultPartSize = 16 << 20
+	maxUploadParts  = 10000
+)
+
+// uploadResumable uploads one file, skipping it when the recorded object is
+// still present remotely and continuing a recorded multipart upload otherwise.
+func (s *S3Service) uploadResumable(ctx context.Context, bucket, key, localPath string, size int64, state UploadStateStore, progress *progressReporter) error {
+	obj, err := state.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("load upload state: %w", err)
+	}
+
+	if obj != nil && obj.Completed && obj.Size == size {
+		ok, err := s.remoteMatches(ctx, bucket, key, size, obj.ETag)
+		if err != nil {
+			return err
+		}
+		if ok {
+			progress.advance(size)
+			return nil
+		}
+	}
+
+	partSize := s.partSizeFor(size)
+	if size <= partSize {
+		return s.putSingle(ctx, bucket, key, localPath, size, state, progress)
+	}
+	return s.putMultipart(ctx, bucket, key, localPath, size, partSize, obj, state, progress)
+}
+
+// remoteMatches reports whether key exists with the expected size and, when known, ETag.
+func (s *S3Service) remoteMatches(ctx context.Context, bucket, key string, size int64, etag string) (bool, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("head object %s: %w", key, err)
+	}
+	if aws.ToInt64(out.ContentLength) != size {
+		return false, nil
+	}
+	return etag == "" || trimETag(aws.ToString(out.ETag)) == trimETag(etag), nil
+}
+
+func (s *S3Service) putSingle(ctx context.Context, bucket, key, localPath string, size int64, state UploadStateStore, progress *progressReporter) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("open file %s: %w", localPath, err)
+	}
+	defer f.Close()
+
+	out, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		Body:          newLimitedReadSeeker(ctx, f, s.limiter),
+		ContentLength: aws.Int64(size),
+		ACL:           types.ObjectCannedACLPrivate,
+	})
+	if err != nil {
+		return fmt.Errorf("put object: %w", err)
+	}
+	progress.advance(size)
+
+	return state.Save(ctx, &domain.UploadObject{
+		Key:       key,
+		Size:      size,
+		Completed: true,
+		ETag:      aws.ToString(out.ETag),
+	})
+}
+
+func (s *S3Service) putMultipart(ctx context.Context, bucket, key, localPath string, size, partSize int64, obj *domain.UploadObject, state UploadStateStore, progress *progressReporter) error {
+	done := make(map[int32]domain.UploadPart)
+	uploadID := ""
+	if obj != nil && obj.UploadID != "" && !obj.Completed && obj.Size == size && obj.PartSize == partSize {
+		remote, err := s.listParts(ctx, bucket, key, obj.UploadID)
+		switch {
+		case err == nil:
+			uploadID = obj.UploadID
+			// trust only parts the server still holds with the recorded ETag
+			for _, part := range obj.Parts {
+				if r, ok := remote[part.Number]; ok && trimETag(aws.ToString(r.ETag)) == trimETag(part.ETag) && aws.ToInt64(r.Size) == part.Size {
+					done[part.Number] = part
+				}
+			}
+		case !isNoSuchUpload(err):
+			return err
+		}
+	}
+
+	if uploadID == "" {
+		out, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			ACL:    types.ObjectCannedACLPrivate,
+		})
+		if err != nil {
+			return fmt.Errorf("create multipart upload: %w", err)
+		}
+		uploadID = aws.ToString(out.UploadId)
+	}
+
+	obj = &domain.UploadObject{
+		Key:      key,
+		Size:     size,
+		UploadID: uploadID,
+		PartSize: partSize,
+		Parts:    make([]domain.UploadPart, 0, len(done)),
+	}
+	for _, part := range done {
+		obj.Parts = append(obj.Parts, part)
+	}
+	if err := state.Save(ctx, obj); err != nil {
+		return fmt.Errorf("save upload state: %w", err)
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("open file %s: %w", localPath, err)
+	}
+	defer f.Close()
+
+	partCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		sem      = make(chan struct{}, s.opts.PartConcurrency)
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	partCount := int32((size + partSize - 1) / partSize)
+	for number := int32(1); number <= partCount; number++ {
+		offset := int64(number-1) * partSize
+		length := min(partSize, size-offset)
+		if _, ok := done[number]; ok {
+			progress.advance(length)
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-partCtx.Done():
+		}
+		if partCtx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(number int32, offset, length int64) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			out, err := s.client.UploadPart(partCtx, &s3.UploadPartInput{
+				Bucket:        aws.String(bucket),
+				Key:           aws.String(key),
+				UploadId:      aws.String(uploadID),
+				PartNumber:    aws.Int32(number),
+				Body:          newLimitedReadSeeker(partCtx, io.NewSectionReader(f, offset, length), s.limiter),
+				ContentLength: aws.Int64(length),
+			})
+			if err != nil {
+				fail(fmt.Errorf("upload part %d: %w", number, err))
+				return
+			}
+
+			part := domain.UploadPart{Number: number, ETag: aws.ToString(out.ETag), Size: length}
+			if err := state.AddPart(partCtx, key, part); err != nil {
+				fail(fmt.Errorf("save upload part: %w", err))
+				return
+			}
+			mu.Lock()
+			done[number] = part
+			mu.Unlock()
+			progress.advance(length)
+		}(number, offset, length)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	completed := make([]types.CompletedPart, 0, len(done))
+	for _, part := range done {
+		completed = append(completed, types.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int32(part.Number),
+		})
+	}
+	sort.Slice(completed, func(i, j int) bool {
+		return aws.ToInt32(completed[i].PartNumber) < aws.ToInt32(completed[j].PartNumber)
+	})
+
+	out, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return fmt.Errorf("complete multipart upload: %w", err)
+	}
+
+	return state.Save(ctx, &domain.UploadObject{
+		Key:       key,
+		Size:      size,
+		PartSize:  partSize,
+		Completed: true,
+		ETag:      aws.ToString(out.ETag),
+	})
+}
+
+func (s *S3Service) listParts(ctx context.Context, bucket, key, uploadID string) (map[int32]types.Part, error) {
+	parts := make(map[int32]types.Part)
+	input := &s3.ListPartsInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	}
+	for {
+		out, err := s.client.ListParts(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("list parts: %w", err)
+		}
+		for _, part := range out.Parts {
+			parts[aws.ToInt32(part.PartNumber)] = part
+		}
+		if !aws.ToBool(out.IsTruncated) || out.NextPartNumberMarker == nil {
+			break
+		}
+		input.PartNumberMarker = out.NextPartNumberMarker
+	}
+	return parts, nil
+}
+
+// partSizeFor keeps large files within the 10,000 part limit of S3.
+func (s *S3Service) partSizeFor(size int64) int64 {
+	partSize := s.opts.PartSize
+	if needed := (size + maxUploadParts - 1) / maxUploadParts; needed > partSize {
+		partSize = needed
+	}
+	return partSize
+}
+
+func isNoSuchUpload(err error) bool {
+	var noSuchUpload *types.NoSuchUpload
+	return errors.As(err, &noSuchUpload)
+}
+
+func trimETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
+
+// discardUploadState stands in when the caller keeps no upload state. Each
+// key is uploaded once per UploadDirectory call, so nothing needs recalling.
+type discardUploadState struct{}
+
+func (discardUploadState) Get(ctx context.Context, key string) (*domain.UploadObject, error) {
+	return nil, nil
+}
+
+func (discardUploadState) Save(ctx context.Context, obj *domain.UploadObject) error {
+	return nil
+}
+
+func (discardUploadState) AddPart(ctx context.Context, key string, part domain.UploadPart) error {
+	return nil
+}
@@ -2,7 +2,18 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
 	"time"
+
+	"github.com/aws/smithy-go"
+
+	"magnet-player/internal/domain"
 )
 
 type ObjectInfo struct {
@@ -16,6 +27,48 @@ type UploadOptions struct {
 	Bucket           string
 	KeyPrefix        string
 	ProgressCallback func(done, total int64)
+	// Files restricts the upload to these slash separated paths relative to
+	// the uploaded directory. An empty list uploads everything.
+	Files []string
+	// State, when set, records per-object progress so that an interrupted
+	// upload resumes instead of starting over.
+	State UploadStateStore
+	// KeepSource leaves the local files in place for backends that would
+	// otherwise move them, e.g. while the torrent is still seeding.
+	KeepSource bool
+	// FileCallback, when set, is told when each file starts and finishes
+	// uploading. Files are named by their slash separated relative path.
+	FileCallback func(name string, status domain.FileUploadStatus)
+}
+
+func (o UploadOptions) reportFile(name string, status domain.FileUploadStatus) {
+	if o.FileCallback != nil {
+		o.FileCallback(name, status)
+	}
+}
+
+// UploadStateStore persists the progress of one UploadDirectory call, keyed by object key.
+type UploadStateStore interface {
+	// Get returns the recorded state for key, or nil when there is none.
+	Get(ctx context.Context, key string) (*domain.UploadObject, error)
+	Save(ctx context.Context, obj *domain.UploadObject) error
+	AddPart(ctx context.Context, key string, part domain.UploadPart) error
+}
+
+// UserKeyPrefix returns the key prefix that namespaces a user's uploads under base.
+func UserKeyPrefix(base string, userID int64) string {
+	userPrefix := fmt.Sprintf("user-%d", userID)
+	base = strings.Trim(base, "/")
+	if base == "" {
+		return userPrefix
+	}
+	return base + "/" + userPrefix
+}
+
+// PresignOptions customizes a presigned download URL.
+type PresignOptions struct {
+	// ContentDisposition overrides the Content-Disposition header of the response.
+	ContentDisposition string
 }
 
 // Service uploads completed downloads to remote object storage.
@@ -23,4 +76,45 @@ type Service interface {
 	UploadDirectory(ctx context.Context, localPath string, opts UploadOptions) (string, error)
 	ListObjects(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error)
 	DeletePrefix(ctx context.Context, bucket, prefix string) error
+	PresignGet(ctx context.Context, bucket, key string, ttl time.Duration, opts PresignOptions) (string, error)
+}
+
+// IsTransient reports whether an upload error is a fault that may clear up
+// on its own. Only these are:
+//   - network timeouts, refused, reset or dropped connections
+//   - HTTP 5xx and 429 responses
+//   - S3 throttling and internal error codes
+//
+// Anything else, including permission and configuration errors, is not.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "SlowDown", "Throttling", "ThrottlingException", "RequestLimitExceeded", "TooManyRequestsException",
+			"RequestTimeout", "InternalError", "ServiceUnavailable":
+			return true
+		}
+	}
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		code := statusErr.HTTPStatusCode()
+		return code >= 500 || code == http.StatusTooManyRequests
+	}
+	return false
 }
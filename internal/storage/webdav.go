@@ -0,0 +1,465 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"magnet-player/internal/domain"
+)
+
+// WebDAVScheme prefixes locations returned by WebDAVService.UploadDirectory.
+const WebDAVScheme = "webdav://"
+
+// ErrPresignUnsupported is returned by backends that cannot hand out direct download links.
+var ErrPresignUnsupported = errors.New("presigned urls are not supported by this storage driver")
+
+// WebDAVConfig describes the WebDAV share uploads are written to.
+type WebDAVConfig struct {
+	// URL is the collection that acts as the storage root, e.g.
+	// https://cloud.example.com/remote.php/dav/files/alice/magnet.
+	URL      string
+	Username string
+	Password string
+	Client   *http.Client
+}
+
+// WebDAVService uploads task data to a WebDAV share such as Nextcloud. The
+// bucket arguments of Service are ignored.
+type WebDAVService struct {
+	base     *url.URL
+	username string
+	password string
+	client   *http.Client
+}
+
+func NewWebDAVService(cfg WebDAVConfig) (*WebDAVService, error) {
+	if strings.TrimSpace(cfg.URL) == "" {
+		return nil, fmt.Errorf("webdav url is required")
+	}
+	base, err := url.Parse(strings.TrimSpace(cfg.URL))
+	if err != nil {
+		return nil, fmt.Errorf("parse webdav url: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("webdav url must be http or https")
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	base.RawPath = ""
+
+	client := cfg.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &WebDAVService{
+		base:     base,
+		username: cfg.Username,
+		password: cfg.Password,
+		client:   client,
+	}, nil
+}
+
+func (s *WebDAVService) UploadDirectory(ctx context.Context, localPath string, opts UploadOptions) (string, error) {
+	root := filepath.Clean(localPath)
+	if fi, err := os.Stat(root); err != nil {
+		return "", fmt.Errorf("stat local path: %w", err)
+	} else if !fi.IsDir() {
+		return "", fmt.Errorf("local path must be a directory")
+	}
+
+	type uploadFile struct {
+		path string
+		rel  string
+		size int64
+	}
+
+	var include map[string]struct{}
+	if len(opts.Files) > 0 {
+		include = make(map[string]struct{}, len(opts.Files))
+		for _, name := range opts.Files {
+			include[strings.Trim(filepath.ToSlash(name), "/")] = struct{}{}
+		}
+	}
+
+	var files []uploadFile
+	err := filepath.Walk(root, func(p string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return fmt.Errorf("relative path for %s: %w", p, err)
+		}
+		if include != nil {
+			if _, ok := include[filepath.ToSlash(rel)]; !ok {
+				return nil
+			}
+		}
+		files = append(files, uploadFile{
+			path: p,
+			rel:  filepath.ToSlash(rel),
+			size: info.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var totalSize int64
+	for _, file := range files {
+		totalSize += file.size
+	}
+
+	progress := newProgressReporter(totalSize, opts.ProgressCallback)
+	if progress != nil {
+		progress.report(0)
+	}
+
+	keyPrefix := strings.Trim(opts.KeyPrefix, "/")
+	if keyPrefix == "" {
+		keyPrefix = fmt.Sprintf("task-%d", os.Getpid())
+	}
+
+	created := make(map[string]struct{})
+	for _, file := range files {
+		key := keyPrefix + "/" + file.rel
+		if err := s.mkcolAll(ctx, path.Dir(key), created); err != nil {
+			return "", err
+		}
+		opts.reportFile(file.rel, domain.FileUploadUploading)
+		if err := s.put(ctx, key, file.path, file.size, progress); err != nil {
+			return "", fmt.Errorf("upload %s: %w", file.path, err)
+		}
+		opts.reportFile(file.rel, domain.FileUploadUploaded)
+	}
+
+	if progress != nil {
+		progress.flush()
+	}
+
+	return WebDAVScheme + keyPrefix, nil
+}
+
+// ListObjects walks the collections below prefix with Depth: 1 PROPFIND
+// requests, since many servers refuse Depth: infinity.
+func (s *WebDAVService) ListObjects(ctx context.Context, bucket, prefix string) ([]ObjectInfo, error) {
+	prefix = strings.TrimPrefix(strings.TrimSpace(prefix), "/")
+
+	start := ""
+	if dir := path.Dir(prefix); dir != "." && prefix != "" {
+		start = dir
+	}
+
+	var objects []ObjectInfo
+	pending := []string{start}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		entries, err := s.propfind(ctx, dir, "1")
+		if err != nil {
+			if errors.Is(err, errWebDAVNotFound) {
+				continue
+			}
+			return nil, fmt.Errorf("list objects: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.key == dir {
+				continue
+			}
+			if entry.collection {
+				// descend only into collections that can still contain matching keys
+				if strings.HasPrefix(entry.key+"/", prefix) || strings.HasPrefix(prefix, entry.key+"/") {
+					pending = append(pending, entry.key)
+				}
+				continue
+			}
+			if !strings.HasPrefix(entry.key, prefix) {
+				continue
+			}
+			objects = append(objects, ObjectInfo{
+				Key:          entry.key,
+				Size:         entry.size,
+				LastModified: entry.modified,
+			})
+		}
+	}
+	return objects, nil
+}
+
+// DeletePrefix removes the collection named by prefix in one request, which
+// WebDAV applies recursively, or else every object whose key starts with prefix.
+func (s *WebDAVService) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	trimmed := strings.Trim(strings.TrimSpace(prefix), "/")
+	if trimmed == "" {
+		return fmt.Errorf("prefix is required")
+	}
+
+	entries, err := s.propfind(ctx, trimmed, "0")
+	switch {
+	case errors.Is(err, errWebDAVNotFound):
+	case err != nil:
+		return fmt.Errorf("stat prefix: %w", err)
+	case len(entries) > 0 && entries[0].collection:
+		return s.delete(ctx, trimmed+"/")
+	}
+
+	objects, err := s.ListObjects(ctx, bucket, trimmed)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := s.delete(ctx, obj.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *WebDAVService) PresignGet(ctx context.Context, bucket, key string, ttl time.Duration, opts PresignOptions) (string, error) {
+	return "", ErrPresignUnsupported
+}
+
+var errWebDAVNotFound = errors.New("webdav resource not found")
+
+type webdavEntry struct {
+	key        string
+	collection bool
+	size       int64
+	modified   *time.Time
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"DAV: collection"`
+				} `xml:"DAV: resourcetype"`
+				ContentLength string `xml:"DAV: getcontentlength"`
+				LastModified  string `xml:"DAV: getlastmodified"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+func (s *WebDAVService) propfind(ctx context.Context, key, depth string) ([]webdavEntry, error) {
+	// collections are addressed with a trailing slash so servers do not redirect
+	target := key
+	if target != "" && depth != "0" {
+		target += "/"
+	}
+	req, err := s.newRequest(ctx, "PROPFIND", target, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", depth)
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("propfind %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errWebDAVNotFound
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, webdavStatusError("propfind", key, resp)
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("decode propfind %s: %w", key, err)
+	}
+
+	entries := make([]webdavEntry, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		entryKey, err := s.keyFromHref(r.Href)
+		if err != nil {
+			return nil, err
+		}
+		entry := webdavEntry{key: entryKey}
+		for _, ps := range r.Propstats {
+			if ps.Status != "" && !strings.Contains(ps.Status, " 200") {
+				continue
+			}
+			if ps.Prop.ResourceType.Collection != nil {
+				entry.collection = true
+			}
+			if ps.Prop.ContentLength != "" {
+				if size, err := strconv.ParseInt(ps.Prop.ContentLength, 10, 64); err == nil {
+					entry.size = size
+				}
+			}
+			if ps.Prop.LastModified != "" {
+				if t, err := http.ParseTime(ps.Prop.LastModified); err == nil {
+					entry.modified = &t
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// mkcolAll creates dir and its missing parents, remembering what already exists.
+func (s *WebDAVService) mkcolAll(ctx context.Context, dir string, created map[string]struct{}) error {
+	if dir == "" || dir == "." {
+		return nil
+	}
+	if _, ok := created[dir]; ok {
+		return nil
+	}
+	if err := s.mkcolAll(ctx, path.Dir(dir), created); err != nil {
+		return err
+	}
+
+	req, err := s.newRequest(ctx, "MKCOL", dir+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("mkcol %s: %w", dir, err)
+	}
+	defer resp.Body.Close()
+
+	// 405 means the collection already exists
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+		return webdavStatusError("mkcol", dir, resp)
+	}
+	created[dir] = struct{}{}
+	return nil
+}
+
+func (s *WebDAVService) put(ctx context.Context, key, localPath string, size int64, progress *progressReporter) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("open file %s: %w", localPath, err)
+	}
+	defer f.Close()
+
+	var body io.Reader = f
+	if progress != nil {
+		body = io.TeeReader(f, progress)
+	}
+	req, err := s.newRequest(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return webdavStatusError("put", key, resp)
+	}
+	return nil
+}
+
+func (s *WebDAVService) delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return webdavStatusError("delete", key, resp)
+	}
+	return nil
+}
+
+func (s *WebDAVService) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	u := *s.base
+	u.Path = s.base.Path + "/" + key
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("build %s request: %w", strings.ToLower(method), err)
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	return req, nil
+}
+
+// keyFromHref converts a multistatus href, absolute URL or path, into a key relative to the base collection.
+func (s *WebDAVService) keyFromHref(href string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", fmt.Errorf("parse href %q: %w", href, err)
+	}
+	rel := strings.TrimPrefix(u.Path, s.base.Path)
+	if len(rel) == len(u.Path) && s.base.Path != "" {
+		return "", fmt.Errorf("href %q is outside the storage root", href)
+	}
+	return strings.Trim(rel, "/"), nil
+}
+
+// WebDAVStatusError is an unexpected response from the WebDAV server.
+type WebDAVStatusError struct {
+	Op         string
+	Key        string
+	Status     string
+	StatusCode int
+	Body       string
+}
+
+func (e *WebDAVStatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s %s: unexpected status %s", e.Op, e.Key, e.Status)
+	}
+	return fmt.Sprintf("%s %s: unexpected status %s: %s", e.Op, e.Key, e.Status, e.Body)
+}
+
+// HTTPStatusCode matches the SDK response errors, so IsTransient treats both alike.
+func (e *WebDAVStatusError) HTTPStatusCode() int { return e.StatusCode }
+
+func webdavStatusError(op, key string, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &WebDAVStatusError{
+		Op:         op,
+		Key:        key,
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Body:       string(bytes.TrimSpace(msg)),
+	}
+}
+
+var _ Service = (*WebDAVService)(nil)
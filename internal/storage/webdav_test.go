@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/webdav"
+
+	"magnet-player/internal/domain"
+)
+
+// davServer runs an in-memory WebDAV share below /dav and records the
+// collections created with MKCOL.
+type davServer struct {
+	*httptest.Server
+	fs webdav.FileSystem
+
+	mu     sync.Mutex
+	mkcols []string
+}
+
+func newDAVServer(t *testing.T) *davServer {
+	t.Helper()
+	s := &davServer{fs: webdav.NewMemFS()}
+	if err := s.fs.Mkdir(context.Background(), "/magnet", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dav := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: s.fs,
+		LockSystem: webdav.NewMemLS(),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "alice" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == "MKCOL" {
+			s.mu.Lock()
+			s.mkcols = append(s.mkcols, strings.TrimPrefix(r.URL.Path, "/dav/magnet/"))
+			s.mu.Unlock()
+		}
+		dav.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *davServer) service(t *testing.T) *WebDAVService {
+	t.Helper()
+	svc, err := NewWebDAVService(WebDAVConfig{
+		URL:      s.URL + "/dav/magnet/",
+		Username: "alice",
+		Password: "secret",
+		Client:   s.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func (s *davServer) exists(name string) bool {
+	_, err := s.fs.Stat(context.Background(), "/magnet/"+name)
+	return err == nil
+}
+
+// writeTree creates files, keyed by slash separated relative path, below a
+// temporary directory and returns it.
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestWebDAVUploadDirectory(t *testing.T) {
+	srv := newDAVServer(t)
+	svc := srv.service(t)
+	local := writeTree(t, map[string]string{
+		"movie.mkv":              "0123456789",
+		"extras/behind.mkv":      "abcde",
+		"extras/subs/en.srt":     "hello",
+		"extras/subs/skip.nfo":   "skipped",
+		"extras/empty/zero.info": "",
+	})
+
+	var (
+		progress [][2]int64
+		files    []string
+	)
+	location, err := svc.UploadDirectory(context.Background(), local, UploadOptions{
+		KeyPrefix: "/user-1/task-7/",
+		Files:     []string{"movie.mkv", "extras/behind.mkv", "extras/subs/en.srt", "extras/empty/zero.info"},
+		ProgressCallback: func(done, total int64) {
+			progress = append(progress, [2]int64{done, total})
+		},
+		FileCallback: func(name string, status domain.FileUploadStatus) {
+			if status == domain.FileUploadUploaded {
+				files = append(files, name)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("UploadDirectory: %v", err)
+	}
+	if want := WebDAVScheme + "user-1/task-7"; location != want {
+		t.Errorf("location = %q, want %q", location, want)
+	}
+
+	for _, name := range []string{"movie.mkv", "extras/behind.mkv", "extras/subs/en.srt", "extras/empty/zero.info"} {
+		if !srv.exists("user-1/task-7/" + name) {
+			t.Errorf("%s was not uploaded", name)
+		}
+	}
+	if srv.exists("user-1/task-7/extras/subs/skip.nfo") {
+		t.Error("file outside opts.Files was uploaded")
+	}
+
+	// every parent collection is created once, parents before children
+	wantMkcols := []string{
+		"user-1/",
+		"user-1/task-7/",
+		"user-1/task-7/extras/",
+		"user-1/task-7/extras/empty/",
+		"user-1/task-7/extras/subs/",
+	}
+	gotMkcols := slices.Clone(srv.mkcols)
+	sort.Strings(gotMkcols)
+	if !slices.Equal(gotMkcols, wantMkcols) {
+		t.Errorf("MKCOL requests = %v, want %v", srv.mkcols, wantMkcols)
+	}
+	for i, dir := range srv.mkcols {
+		for _, child := range srv.mkcols[:i] {
+			if strings.HasPrefix(child, dir) {
+				t.Errorf("MKCOL %s sent after its child %s", dir, child)
+			}
+		}
+	}
+
+	const total = 20
+	if len(progress) < 2 {
+		t.Fatalf("progress callback called %d times, want at least 2", len(progress))
+	}
+	if first := progress[0]; first != [2]int64{0, total} {
+		t.Errorf("first progress = %v, want [0 %d]", first, total)
+	}
+	if last := progress[len(progress)-1]; last != [2]int64{total, total} {
+		t.Errorf("last progress = %v, want [%d %d]", last, total, total)
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i][0] < progress[i-1][0] {
+			t.Errorf("progress went backwards: %v", progress)
+			break
+		}
+	}
+	if len(files) != 4 {
+		t.Errorf("uploaded file callbacks = %v, want 4 files", files)
+	}
+}
+
+func TestWebDAVUploadDirectoryExistingCollections(t *testing.T) {
+	srv := newDAVServer(t)
+	svc := srv.service(t)
+	local := writeTree(t, map[string]string{"a/b.txt": "b"})
+
+	// a second upload into the same prefix meets collections that already exist
+	for range 2 {
+		if _, err := svc.UploadDirectory(context.Background(), local, UploadOptions{KeyPrefix: "task-1"}); err != nil {
+			t.Fatalf("UploadDirectory: %v", err)
+		}
+	}
+	if !srv.exists("task-1/a/b.txt") {
+		t.Error("a/b.txt was not uploaded")
+	}
+}
+
+func TestWebDAVListObjects(t *testing.T) {
+	srv := newDAVServer(t)
+	svc := srv.service(t)
+	local := writeTree(t, map[string]string{
+		"one.mkv":         "1",
+		"season/two.mkv":  "22",
+		"season/s/3.mkv":  "333",
+		"season/s/4 .srt": "4444",
+	})
+	ctx := context.Background()
+	for _, prefix := range []string{"task-1", "task-10", "task-2"} {
+		if _, err := svc.UploadDirectory(ctx, local, UploadOptions{KeyPrefix: prefix}); err != nil {
+			t.Fatalf("UploadDirectory %s: %v", prefix, err)
+		}
+	}
+
+	keys := func(prefix string) []string {
+		return []string{prefix + "/one.mkv", prefix + "/season/s/3.mkv", prefix + "/season/s/4 .srt", prefix + "/season/two.mkv"}
+	}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"task-1/", keys("task-1")},
+		{"task-1", append(keys("task-1"), keys("task-10")...)},
+		{"task-1/season/s", []string{"task-1/season/s/3.mkv", "task-1/season/s/4 .srt"}},
+		{"task-2/one", []string{"task-2/one.mkv"}},
+		{"task-3/", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			objects, err := svc.ListObjects(ctx, "", tt.prefix)
+			if err != nil {
+				t.Fatalf("ListObjects: %v", err)
+			}
+			var got []string
+			for _, obj := range objects {
+				got = append(got, obj.Key)
+				if obj.LastModified == nil {
+					t.Errorf("%s has no modification time", obj.Key)
+				}
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	objects, err := svc.ListObjects(ctx, "", "task-2/season/s/3")
+	if err != nil || len(objects) != 1 {
+		t.Fatalf("ListObjects = %v, %v; want one object", objects, err)
+	}
+	if objects[0].Size != 3 {
+		t.Errorf("size = %d, want 3", objects[0].Size)
+	}
+}
+
+func TestWebDAVDeletePrefix(t *testing.T) {
+	srv := newDAVServer(t)
+	svc := srv.service(t)
+	local := writeTree(t, map[string]string{"a.mkv": "a", "sub/b.mkv": "b"})
+	ctx := context.Background()
+	for _, prefix := range []string{"task-1", "task-10", "task-2"} {
+		if _, err := svc.UploadDirectory(ctx, local, UploadOptions{KeyPrefix: prefix}); err != nil {
+			t.Fatalf("UploadDirectory %s: %v", prefix, err)
+		}
+	}
+
+	// a collection is removed in one request
+	if err := svc.DeletePrefix(ctx, "", "task-1/"); err != nil {
+		t.Fatalf("DeletePrefix task-1/: %v", err)
+	}
+	if srv.exists("task-1") {
+		t.Error("task-1 still exists")
+	}
+	if !srv.exists("task-10/sub/b.mkv") {
+		t.Error("deleting task-1 removed task-10")
+	}
+
+	// a prefix that is not a collection removes the matching objects
+	if err := svc.DeletePrefix(ctx, "", "task-2/a"); err != nil {
+		t.Fatalf("DeletePrefix task-2/a: %v", err)
+	}
+	if srv.exists("task-2/a.mkv") {
+		t.Error("task-2/a.mkv still exists")
+	}
+	if !srv.exists("task-2/sub/b.mkv") {
+		t.Error("task-2/sub/b.mkv was removed")
+	}
+
+	// missing prefixes are not an error
+	if err := svc.DeletePrefix(ctx, "", "task-404"); err != nil {
+		t.Errorf("DeletePrefix missing: %v", err)
+	}
+	if err := svc.DeletePrefix(ctx, "", "/"); err == nil {
+		t.Error("DeletePrefix accepted an empty prefix")
+	}
+}
@@ -3,14 +3,20 @@
 import { useCallback, useEffect, useMemo, useRef, useState } from "react";
 import {
   API_ROUTES,
+  SESSION_REFRESHED_EVENT,
+  TOKEN_STORAGE_KEY,
+  clearSession,
   createTask,
   deleteTask,
   fetchObjects,
   fetchTasks,
   fetchCurrentUser,
   login,
+  refreshSession,
   registerUser,
   resolveApiBaseUrl,
+  storeSession,
+  tokenExpiry,
 } from "../lib/api";
 
 const STATUS_COLORS = {
@@ -31,7 +37,8 @@ const OBJECT_SIGNING_QUERY =
   process.env.NEXT_PUBLIC_OBJECT_SIGNING_QUERY ?? "";
 const VIDEO_EXTENSIONS = ["mp4", "m4v", "mov", "webm", "ogg", "mkv"];
 const AUTO_REFRESH_INTERVAL = 10000;
-const TOKEN_STORAGE_KEY = "magnet-player.auth.token";
+// TOKEN_REFRESH_MARGIN renews the access token this long before it expires.
+const TOKEN_REFRESH_MARGIN = 60 * 1000;
 const USER_STORAGE_KEY = "magnet-player.auth.user";
 
 function isVideoObject(key) {
@@ -160,11 +167,50 @@ export default function Home() {
     tasksRequestIdRef.current = 0;
     objectsRequestIdRef.current = 0;
     if (typeof window !== "undefined") {
-      window.localStorage.removeItem(TOKEN_STORAGE_KEY);
+      clearSession();
       window.localStorage.removeItem(USER_STORAGE_KEY);
     }
   }, [setAuthForm, setAuthMode]);
 
+  useEffect(() => {
+    const handleRefreshed = (event) => {
+      if (event.detail?.token) {
+        setAuthToken(event.detail.token);
+      }
+      if (event.detail?.user) {
+        setCurrentUser(event.detail.user);
+      }
+    };
+    window.addEventListener(SESSION_REFRESHED_EVENT, handleRefreshed);
+    return () =>
+      window.removeEventListener(SESSION_REFRESHED_EVENT, handleRefreshed);
+  }, []);
+
+  useEffect(() => {
+    if (!authToken) {
+      return undefined;
+    }
+    const expiresAt = tokenExpiry();
+    if (!expiresAt) {
+      return undefined;
+    }
+    const delay = Math.max(
+      expiresAt.getTime() - Date.now() - TOKEN_REFRESH_MARGIN,
+      0
+    );
+    const timeoutId = setTimeout(async () => {
+      try {
+        await refreshSession();
+      } catch (err) {
+        if (err?.status === 401 || err?.status === 403) {
+          handleLogout();
+          setAuthMessage("登录已过期，请重新登录");
+        }
+      }
+    }, delay);
+    return () => clearTimeout(timeoutId);
+  }, [authToken, handleLogout, setAuthMessage]);
+
   useEffect(() => {
     if (!authToken) {
       return;
@@ -463,7 +509,7 @@ export default function Home() {
         setAuthToken(result.token);
         setCurrentUser(result.user ?? null);
         if (typeof window !== "undefined") {
-          window.localStorage.setItem(TOKEN_STORAGE_KEY, result.token);
+          storeSession(result);
           if (result.user) {
             window.localStorage.setItem(
               USER_STORAGE_KEY,
@@ -739,9 +785,11 @@ export default function Home() {
                     task.downloadedBytes ??
                     task.DownloadedBytes;
                   const totalSize =
-                    task.total_size ?? task.totalSize ?? task.TotalSize;
+                    (task.total_size ?? task.totalSize ?? task.TotalSize) ||
+                    task.expected_size;
                   const name =
-                    task.torrent_name ?? task.torrentName ?? task.TorrentName;
+                    (task.torrent_name ?? task.torrentName ?? task.TorrentName) ||
+                    task.display_name;
                   const updatedAt =
                     task.updated_at ?? task.updatedAt ?? task.UpdatedAt;
                   const s3Location =
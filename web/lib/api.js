@@ -9,8 +9,17 @@ export const API_ROUTES = {
   authLogin: `${API_BASE_URL}/api/auth/login`,
   authRegister: `${API_BASE_URL}/api/auth/register`,
   authMe: `${API_BASE_URL}/api/auth/me`,
+  authRefresh: `${API_BASE_URL}/api/auth/refresh`,
 };
 
+export const TOKEN_STORAGE_KEY = "magnet-player.auth.token";
+const REFRESH_TOKEN_STORAGE_KEY = "magnet-player.auth.refresh";
+const TOKEN_EXPIRY_STORAGE_KEY = "magnet-player.auth.expires";
+
+// SESSION_REFRESHED_EVENT is dispatched on window with the new auth payload
+// whenever the access token is renewed.
+export const SESSION_REFRESHED_EVENT = "magnet-player:session-refreshed";
+
 function buildHeaders(token, base = {}) {
   const headers = { ...base };
   if (token) {
@@ -42,6 +51,102 @@ function buildError(response, payload, fallbackMessage) {
   return error;
 }
 
+function storageAvailable() {
+  return typeof window !== "undefined" && Boolean(window.localStorage);
+}
+
+// storeSession keeps the tokens of a login, register or refresh response.
+export function storeSession(result) {
+  if (!storageAvailable() || !result?.token) {
+    return;
+  }
+  window.localStorage.setItem(TOKEN_STORAGE_KEY, result.token);
+  if (result.refresh_token) {
+    window.localStorage.setItem(REFRESH_TOKEN_STORAGE_KEY, result.refresh_token);
+  }
+  if (result.expires_at) {
+    window.localStorage.setItem(TOKEN_EXPIRY_STORAGE_KEY, result.expires_at);
+  }
+}
+
+export function clearSession() {
+  if (!storageAvailable()) {
+    return;
+  }
+  window.localStorage.removeItem(TOKEN_STORAGE_KEY);
+  window.localStorage.removeItem(REFRESH_TOKEN_STORAGE_KEY);
+  window.localStorage.removeItem(TOKEN_EXPIRY_STORAGE_KEY);
+}
+
+// tokenExpiry returns when the stored access token expires, or null.
+export function tokenExpiry() {
+  if (!storageAvailable()) {
+    return null;
+  }
+  const value = window.localStorage.getItem(TOKEN_EXPIRY_STORAGE_KEY);
+  const date = value ? new Date(value) : null;
+  return date && !Number.isNaN(date.getTime()) ? date : null;
+}
+
+let pendingRefresh = null;
+
+// refreshSession trades the stored refresh token for a new access token.
+// Concurrent callers share one request, since each refresh token is single use.
+export function refreshSession() {
+  if (!pendingRefresh) {
+    pendingRefresh = doRefreshSession().finally(() => {
+      pendingRefresh = null;
+    });
+  }
+  return pendingRefresh;
+}
+
+async function doRefreshSession() {
+  const refreshToken = storageAvailable()
+    ? window.localStorage.getItem(REFRESH_TOKEN_STORAGE_KEY)
+    : null;
+  if (!refreshToken) {
+    const error = new Error("登录已过期，请重新登录");
+    error.status = 401;
+    throw error;
+  }
+  const response = await fetch(API_ROUTES.authRefresh, {
+    method: "POST",
+    headers: { "Content-Type": "application/json" },
+    body: JSON.stringify({ refresh_token: refreshToken }),
+  });
+  const payload = await parseJson(response);
+  if (!response.ok) {
+    if (response.status === 401 || response.status === 403) {
+      clearSession();
+    }
+    throw buildError(response, payload, "登录已过期，请重新登录");
+  }
+  storeSession(payload);
+  window.dispatchEvent(
+    new CustomEvent(SESSION_REFRESHED_EVENT, { detail: payload })
+  );
+  return payload;
+}
+
+// authorizedFetch sends an authenticated request. When the access token has
+// expired it refreshes the session once and retries with the new token.
+async function authorizedFetch(url, init, token) {
+  const send = (accessToken) =>
+    fetch(url, { ...init, headers: buildHeaders(accessToken, init.headers) });
+  const response = await send(token);
+  if (response.status !== 401 || !token) {
+    return response;
+  }
+  let session;
+  try {
+    session = await refreshSession();
+  } catch (err) {
+    return response;
+  }
+  return send(session.token);
+}
+
 export async function login(username, password) {
   const response = await fetch(API_ROUTES.authLogin, {
     method: "POST",
@@ -69,11 +174,15 @@ export async function registerUser(username, password, registerSecret) {
 }
 
 export async function fetchCurrentUser(token) {
-  const response = await fetch(API_ROUTES.authMe, {
-    method: "GET",
-    headers: buildHeaders(token, { "Content-Type": "application/json" }),
-    cache: "no-store",
-  });
+  const response = await authorizedFetch(
+    API_ROUTES.authMe,
+    {
+      method: "GET",
+      headers: { "Content-Type": "application/json" },
+      cache: "no-store",
+    },
+    token
+  );
   const payload = await parseJson(response);
   if (!response.ok) {
     throw buildError(response, payload, "获取用户信息失败");
@@ -82,11 +191,15 @@ export async function fetchCurrentUser(token) {
 }
 
 export async function fetchTasks(token) {
-  const response = await fetch(API_ROUTES.tasks, {
-    method: "GET",
-    headers: buildHeaders(token, { "Content-Type": "application/json" }),
-    cache: "no-store",
-  });
+  const response = await authorizedFetch(
+    API_ROUTES.tasks,
+    {
+      method: "GET",
+      headers: { "Content-Type": "application/json" },
+      cache: "no-store",
+    },
+    token
+  );
   const payload = await parseJson(response);
   if (!response.ok) {
     throw buildError(response, payload, "Failed to load tasks");
@@ -95,11 +208,15 @@ export async function fetchTasks(token) {
 }
 
 export async function createTask(magnet, token) {
-  const response = await fetch(API_ROUTES.tasks, {
-    method: "POST",
-    headers: buildHeaders(token, { "Content-Type": "application/json" }),
-    body: JSON.stringify({ magnet }),
-  });
+  const response = await authorizedFetch(
+    API_ROUTES.tasks,
+    {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ magnet }),
+    },
+    token
+  );
   const payload = await parseJson(response);
   if (!response.ok) {
     throw buildError(response, payload, "Failed to create task");
@@ -116,10 +233,14 @@ export async function deleteTask(id, options = {}) {
   if (deleteRemote) {
     url.searchParams.set("delete_remote", "true");
   }
-  const response = await fetch(url, {
-    method: "DELETE",
-    headers: buildHeaders(token, { "Content-Type": "application/json" }),
-  });
+  const response = await authorizedFetch(
+    url,
+    {
+      method: "DELETE",
+      headers: { "Content-Type": "application/json" },
+    },
+    token
+  );
   const payload = await parseJson(response);
   if (!response.ok) {
     throw buildError(response, payload, "Failed to delete task");
@@ -132,11 +253,15 @@ export async function fetchObjects(prefix = "", token) {
   if (prefix.trim().length > 0) {
     url.searchParams.set("prefix", prefix.trim());
   }
-  const response = await fetch(url, {
-    method: "GET",
-    headers: buildHeaders(token, { "Content-Type": "application/json" }),
-    cache: "no-store",
-  });
+  const response = await authorizedFetch(
+    url,
+    {
+      method: "GET",
+      headers: { "Content-Type": "application/json" },
+      cache: "no-store",
+    },
+    token
+  );
   const payload = await parseJson(response);
   if (!response.ok) {
     throw buildError(response, payload, "Failed to load objects");